@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Samudra-G/stockprediction-refactored/backtest"
+	"github.com/gin-gonic/gin"
+)
+
+// strategyParams are the optional form fields forwarded to backtest.New
+var strategyParams = []string{"fast", "slow", "period", "oversold", "overbought"}
+
+// Backtest replays the uploaded CSV through a built-in strategy. It takes the
+// same upload as /metric plus a "strategy" field, optional strategy params
+// and optional cost overrides (initial_cash, commission, commission_rate,
+// slippage).
+func (h *Handler) Backtest(c *gin.Context) {
+	logger := loggerFrom(c.Request.Context())
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		logger.Warn("Failed to get form file", "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required"})
+		return
+	}
+
+	params, err := formFloats(c, strategyParams...)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	strategy, err := backtest.New(c.DefaultPostForm("strategy", "sma_cross"), params)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	costs, err := formFloats(c, "initial_cash", "commission", "commission_rate", "slippage")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cfg := backtest.DefaultConfig()
+	if v, ok := costs["initial_cash"]; ok {
+		cfg.InitialCash = v
+	}
+	if v, ok := costs["commission"]; ok {
+		cfg.Commission = v
+	}
+	if v, ok := costs["commission_rate"]; ok {
+		cfg.CommissionRate = v
+	}
+	if v, ok := costs["slippage"]; ok {
+		cfg.Slippage = v
+	}
+
+	series, _, err := h.parseCSV(c.Request.Context(), file)
+	if err != nil {
+		logger.Warn("Failed to parse CSV", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := backtest.Run(series, strategy, cfg)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// formFloats parses the given form fields that are present
+func formFloats(c *gin.Context, keys ...string) (map[string]float64, error) {
+	out := make(map[string]float64)
+	for _, key := range keys {
+		raw, ok := c.GetPostForm(key)
+		if !ok || raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", key, raw)
+		}
+		out[key] = v
+	}
+	return out, nil
+}
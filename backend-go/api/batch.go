@@ -0,0 +1,275 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Samudra-G/stockprediction-refactored/store"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+// batchUpload is the CSV of one ticker within a batch. err is set when the
+// part could not be read or the ticker is not valid, the ticker then fails
+// on its own.
+type batchUpload struct {
+	ticker   string
+	fileName string
+	data     []byte
+	err      error
+}
+
+// batchResult is the outcome of one ticker. A ticker whose metrics were
+// computed succeeds even when its prediction could not be queued, the
+// reason is then in PredictionError.
+type batchResult struct {
+	Ticker          string         `json:"ticker"`
+	Status          string         `json:"status"`
+	Error           string         `json:"error,omitempty"`
+	JobID           string         `json:"job_id,omitempty"`
+	PredictionError string         `json:"prediction_error,omitempty"`
+	Metrics         *MetricResults `json:"metrics,omitempty"`
+}
+
+// uploadTicker normalizes the ticker an upload is named by. An invalid one is
+// kept as given along with the error, so the result still names it.
+func uploadTicker(name string) (string, error) {
+	ticker, err := store.NormalizeTicker(name)
+	if err != nil {
+		return strings.TrimSpace(name), err
+	}
+	return ticker, nil
+}
+
+// tickerColumn is the column of a long-format CSV holding several tickers
+const tickerColumn = "Ticker"
+
+// splitByTicker splits a long-format CSV on its Ticker column into one CSV
+// per ticker, in order of first appearance, each with the original header.
+// ok is false when the CSV has no Ticker column.
+func splitByTicker(data []byte) (uploads []batchUpload, ok bool, err error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	col := -1
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), tickerColumn) {
+			col = i
+			break
+		}
+	}
+	if col == -1 {
+		return nil, false, nil
+	}
+
+	rows := make(map[string][][]string)
+	invalid := make(map[string]error)
+	var order []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, true, fmt.Errorf("error reading CSV row: %w", err)
+		}
+		if col >= len(record) || strings.TrimSpace(record[col]) == "" {
+			continue
+		}
+		ticker, err := uploadTicker(record[col])
+		if _, seen := rows[ticker]; !seen {
+			order = append(order, ticker)
+			invalid[ticker] = err
+		}
+		rows[ticker] = append(rows[ticker], record)
+	}
+
+	for _, ticker := range order {
+		buf := &bytes.Buffer{}
+		w := csv.NewWriter(buf)
+		w.Write(header)
+		w.WriteAll(rows[ticker])
+		err := invalid[ticker]
+		if err == nil {
+			err = w.Error()
+		}
+		uploads = append(uploads, batchUpload{ticker: ticker, fileName: ticker + ".csv", data: buf.Bytes(), err: err})
+	}
+	return uploads, true, nil
+}
+
+// readBatchUploads turns every file part into per-ticker uploads. A part
+// with a Ticker column may hold any number of tickers, any other part is
+// one ticker named by its file name, e.g. AAPL.csv.
+func readBatchUploads(files []*multipart.FileHeader) []batchUpload {
+	var uploads []batchUpload
+	for _, file := range files {
+		ticker, tickerErr := uploadTicker(strings.TrimSuffix(filepath.Base(file.Filename), filepath.Ext(file.Filename)))
+		data, err := readPart(file)
+		if err != nil {
+			uploads = append(uploads, batchUpload{ticker: ticker, fileName: file.Filename, err: err})
+			continue
+		}
+		split, ok, err := splitByTicker(data)
+		switch {
+		case err != nil:
+			uploads = append(uploads, batchUpload{ticker: ticker, fileName: file.Filename, err: err})
+		case ok:
+			uploads = append(uploads, split...)
+		default:
+			uploads = append(uploads, batchUpload{ticker: ticker, fileName: file.Filename, data: data, err: tickerErr})
+		}
+	}
+	return uploads
+}
+
+func readPart(file *multipart.FileHeader) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file data: %w", err)
+	}
+	return data, nil
+}
+
+// MetricBatch handles POST /metric/batch. The CSVs come as any number of
+// "file" parts, one long-format CSV with a Ticker column or a mix of both,
+// and take the /metric form options, which apply to every ticker.
+// Predictions are queued as batch priority unless the request says
+// otherwise, a ticker waits for room in the queue while the request lasts
+// rather than being turned away. A ticker that fails is reported in its own result and never
+// fails the batch.
+func (h *Handler) MetricBatch(c *gin.Context) {
+	if !h.predictionService.Accepting() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrShuttingDown.Error()})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one CSV file is required"})
+		return
+	}
+	opts, err := parseMetricOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if c.PostForm("priority") == "" {
+		opts.priority = PriorityBatch
+	}
+
+	uploads := readBatchUploads(form.File["file"])
+	if len(uploads) > h.batch.MaxTickers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf(
+			"batch has %d tickers, at most %d are allowed", len(uploads), h.batch.MaxTickers)})
+		return
+	}
+
+	// A ticker given twice would queue two predictions for the same job
+	// history, the first occurrence wins
+	seen := make(map[string]bool, len(uploads))
+	for i := range uploads {
+		if uploads[i].err == nil && seen[uploads[i].ticker] {
+			uploads[i].err = fmt.Errorf("duplicate ticker %s", uploads[i].ticker)
+		}
+		seen[uploads[i].ticker] = true
+	}
+
+	ctx := c.Request.Context()
+	client, reqID := clientID(c), requestID(c)
+	results := make([]batchResult, len(uploads))
+	g := &errgroup.Group{}
+	g.SetLimit(h.batch.Workers)
+	for i, upload := range uploads {
+		g.Go(func() error {
+			results[i] = h.batchTicker(ctx, upload, opts, client, reqID)
+			return nil
+		})
+	}
+	g.Wait()
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == "error" {
+			failed++
+		}
+	}
+	batchTickers.WithLabelValues("ok").Add(float64(len(results) - failed))
+	batchTickers.WithLabelValues("error").Add(float64(failed))
+	loggerFrom(ctx).Info("Processed metric batch", "tickers", len(results), "failed", failed)
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+	})
+}
+
+// batchTicker runs the /metric pipeline for one ticker of a batch
+func (h *Handler) batchTicker(ctx context.Context, upload batchUpload, opts metricOptions, client, reqID string) batchResult {
+	res := batchResult{Ticker: upload.ticker, Status: "error"}
+	if upload.err != nil {
+		res.Error = upload.err.Error()
+		return res
+	}
+	ticker := upload.ticker
+	if err := ctx.Err(); err != nil {
+		res.Error = "request cancelled"
+		return res
+	}
+
+	logger := loggerFrom(ctx).With("ticker", ticker)
+	series, err := h.parseCSVData(ctx, upload.data)
+	if err != nil {
+		logger.Warn("Failed to parse CSV", "error", err)
+		res.Error = err.Error()
+		return res
+	}
+	results, err := h.cachedMetrics(metricCacheKey(series, opts, h.indicators), series, opts)
+	if err != nil {
+		logger.Warn("Failed to process metrics", "error", err)
+		res.Error = err.Error()
+		return res
+	}
+	h.live.reset(ticker, h.storeSeries(ctx, ticker, series))
+	res.Status = "ok"
+	res.Metrics = results
+
+	jobID, err := h.queuePredictionWait(ctx, PredictionRequest{
+		FileData:  upload.data,
+		FileName:  upload.fileName,
+		Series:    series,
+		Priority:  opts.priority,
+		ClientID:  client,
+		RequestID: reqID,
+	}, ticker)
+	switch {
+	case errors.Is(err, ErrQueueFull) || errors.Is(err, ErrClientQueueFull):
+		retryAfter := int(h.predictionService.RetryAfter().Seconds())
+		res.PredictionError = err.Error() + ", retry after " + strconv.Itoa(retryAfter) + "s"
+	case err != nil:
+		logger.Warn("Failed to queue prediction", "error", err)
+		res.PredictionError = err.Error()
+	default:
+		res.JobID = jobID
+		results.JobID = jobID
+	}
+	return res
+}
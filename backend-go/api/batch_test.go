@@ -0,0 +1,208 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupBatchTest is setupTest whose prediction workers are drained when the
+// test ends, so batch jobs do not log into later tests
+func setupBatchTest(t *testing.T) (*Handler, *gin.Engine) {
+	handler, router := setupTest()
+	t.Cleanup(func() { handler.Shutdown(context.Background()) })
+	return handler, router
+}
+
+// postFiles sends the files, name to content, as "file" parts along with
+// the form fields
+func postFiles(t *testing.T, router http.Handler, path string, fields url.Values, files ...[2]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, values := range fields {
+		for _, value := range values {
+			require.NoError(t, writer.WriteField(key, value))
+		}
+	}
+	for _, file := range files {
+		part, err := writer.CreateFormFile("file", file[0])
+		require.NoError(t, err)
+		part.Write([]byte(file[1]))
+	}
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", path, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func postBatch(t *testing.T, router *gin.Engine, fields map[string]string, files ...[2]string) (int, map[string]any) {
+	t.Helper()
+	values := url.Values{}
+	for key, value := range fields {
+		values.Set(key, value)
+	}
+	w := postFiles(t, router, "/metric/batch", values, files...)
+
+	var resp map[string]any
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return w.Code, resp
+}
+
+func batchResults(resp map[string]any) map[string]map[string]any {
+	byTicker := map[string]map[string]any{}
+	for _, r := range resp["results"].([]any) {
+		result := r.(map[string]any)
+		byTicker[result["ticker"].(string)] = result
+	}
+	return byTicker
+}
+
+func TestHandler_MetricBatch(t *testing.T) {
+	_, router := setupBatchTest(t)
+
+	longFormat := "Date,Close,Ticker\n" +
+		"2023-01-02,100,msft\n2023-01-02,50,GOOG\n" +
+		"2023-01-03,101,msft\n2023-01-03,51,GOOG\n" +
+		"2023-01-04,99,msft\n"
+	code, resp := postBatch(t, router, map[string]string{"indicators": "rsi:2"},
+		[2]string{"AAPL.csv", "Date,Close\n2023-01-02,10\n2023-01-03,11\n2023-01-04,12\n"},
+		[2]string{"watchlist.csv", longFormat},
+		[2]string{"BAD.csv", "Date,Open\n2023-01-02,1\n"},
+		[2]string{"aapl.csv", "Date,Close\n2023-01-02,10\n"},
+	)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(3), resp["succeeded"])
+	assert.Equal(t, float64(2), resp["failed"])
+
+	results := resp["results"].([]any)
+	require.Len(t, results, 5)
+	order := []string{}
+	for _, r := range results {
+		order = append(order, r.(map[string]any)["ticker"].(string))
+	}
+	assert.Equal(t, []string{"AAPL", "MSFT", "GOOG", "BAD", "AAPL"}, order, "results keep the upload order")
+
+	byTicker := batchResults(resp)
+	msft := byTicker["MSFT"]
+	assert.Equal(t, "ok", msft["status"])
+	assert.NotEmpty(t, msft["job_id"])
+	metrics := msft["metrics"].(map[string]any)
+	assert.Len(t, metrics["dates"], 3)
+	assert.Contains(t, metrics["indicators"], "rsi:2")
+	assert.Len(t, byTicker["GOOG"]["metrics"].(map[string]any)["dates"], 2)
+
+	assert.Equal(t, "error", byTicker["BAD"]["status"])
+	assert.Contains(t, byTicker["BAD"]["error"], "Close")
+	assert.Nil(t, byTicker["BAD"]["metrics"])
+	assert.Contains(t, results[4].(map[string]any)["error"], "duplicate ticker")
+}
+
+func TestHandler_MetricBatch_NotEnoughData(t *testing.T) {
+	_, router := setupBatchTest(t)
+
+	code, resp := postBatch(t, router, nil,
+		[2]string{"ONE.csv", "Date,Close\n2023-01-02,10\n"},
+		[2]string{"TWO.csv", "Date,Close\n2023-01-02,10\n2023-01-03,11\n"},
+	)
+	require.Equal(t, http.StatusOK, code)
+	byTicker := batchResults(resp)
+	assert.Equal(t, "error", byTicker["ONE"]["status"])
+	assert.Equal(t, "not enough close prices", byTicker["ONE"]["error"])
+	assert.Equal(t, "ok", byTicker["TWO"]["status"])
+	// One return has no volatility, which must not break the response
+	assert.Nil(t, byTicker["TWO"]["metrics"].(map[string]any)["volatility"])
+}
+
+func TestHandler_MetricBatch_Limits(t *testing.T) {
+	handler, router := setupBatchTest(t)
+
+	code, _ := postBatch(t, router, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = postBatch(t, router, map[string]string{"priority": "urgent"},
+		[2]string{"AAPL.csv", "Date,Close\n2023-01-02,10\n2023-01-03,11\n"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	handler.batch.MaxTickers = 2
+	code, _ = postBatch(t, router, nil,
+		[2]string{"all.csv", "Close,Ticker\n1,A\n2,B\n3,C\n"})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestHandler_MetricBatch_DefaultQueue(t *testing.T) {
+	// The default queue sizes with no workers, the test takes jobs off the
+	// queue itself so the batch has to wait for room
+	cfg := testConfig(0)
+	require.Greater(t, 12, cfg.Predictions.MaxPerClient)
+	handler := newTestHandler(cfg)
+	t.Cleanup(func() { handler.Shutdown(context.Background()) })
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/metric/batch", handler.MetricBatch)
+
+	var files [][2]string
+	for i := 0; i < 12; i++ {
+		files = append(files, [2]string{fmt.Sprintf("T%02d.csv", i),
+			fmt.Sprintf("Date,Close\n2023-01-02,%d\n2023-01-03,%d\n", 10+i, 11+i)})
+	}
+	// A job is taken only once the client holds its full share
+	popped, stop := make(chan int), make(chan struct{})
+	go func() {
+		queue, n := handler.predictionService.queue, 0
+		for n < len(files) {
+			if queue.len() < min(cfg.Predictions.MaxPerClient, len(files)-n) {
+				select {
+				case <-stop:
+					popped <- n + queue.len()
+					return
+				case <-time.After(time.Millisecond):
+				}
+				continue
+			}
+			if _, ok := queue.pop(); !ok {
+				break
+			}
+			n++
+		}
+		popped <- n
+	}()
+
+	code, resp := postBatch(t, router, nil, files...)
+	close(stop)
+	require.Equal(t, http.StatusOK, code)
+	for ticker, result := range batchResults(resp) {
+		assert.NotEmpty(t, result["job_id"], ticker)
+		assert.Nil(t, result["prediction_error"], ticker)
+	}
+	assert.Equal(t, len(files), <-popped, "every ticker is queued")
+}
+
+func TestSplitByTicker(t *testing.T) {
+	uploads, ok, err := splitByTicker([]byte("Date,Close,ticker\n2023-01-02,1,a\n2023-01-02,2,\n2023-01-03,3,A\n"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "A", uploads[0].ticker)
+	assert.Equal(t, "Date,Close,ticker\n2023-01-02,1,a\n2023-01-03,3,A\n", string(uploads[0].data))
+
+	_, ok, err = splitByTicker([]byte("Date,Close\n2023-01-02,1\n"))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
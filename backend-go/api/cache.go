@@ -0,0 +1,184 @@
+package api
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/config"
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+// lruCache is a least-recently-used cache whose entries also expire after a
+// TTL. It is bounded both by entry count and by the approximate size the
+// caller assigns to each entry.
+type lruCache[V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	ttl        time.Duration
+	now        func() time.Time
+
+	order *list.List
+	items map[string]*list.Element
+	bytes int64
+}
+
+type cacheEntry[V any] struct {
+	key     string
+	value   V
+	size    int64
+	expires time.Time
+}
+
+// newLRUCache returns nil, a cache that stores nothing, when maxEntries is 0
+func newLRUCache[V any](maxEntries int, maxBytes int64, ttl time.Duration) *lruCache[V] {
+	if maxEntries <= 0 {
+		return nil
+	}
+	return &lruCache[V]{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		now:        time.Now,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache[V]) get(key string) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*cacheEntry[V])
+	if c.ttl > 0 && !c.now().Before(entry.expires) {
+		c.removeElement(el)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// add stores value under key. An entry larger than the whole byte budget is
+// not cached.
+func (c *lruCache[V]) add(key string, value V, size int64) {
+	if c == nil || (c.maxBytes > 0 && size > c.maxBytes) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	entry := &cacheEntry[V]{key: key, value: value, size: size, expires: c.now().Add(c.ttl)}
+	c.items[key] = c.order.PushFront(entry)
+	c.bytes += size
+
+	for c.order.Len() > c.maxEntries || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *lruCache[V]) removeElement(el *list.Element) {
+	entry := c.order.Remove(el).(*cacheEntry[V])
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
+
+func (c *lruCache[V]) len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// metricCacheKey hashes everything processMetrics depends on: the parsed
+// bars, the risk options and the indicators, selected or default
+func metricCacheKey(series *pkg.Series, opts metricOptions, defaults config.Indicators) string {
+	h := sha256.New()
+	writeFloat := func(v float64) { binary.Write(h, binary.LittleEndian, math.Float64bits(v)) }
+
+	binary.Write(h, binary.LittleEndian, int64(series.Len()))
+	for _, bar := range series.Bars {
+		binary.Write(h, binary.LittleEndian, bar.Time.UnixNano())
+		for _, v := range []float64{bar.Open, bar.High, bar.Low, bar.Close, bar.AdjClose, bar.Volume} {
+			writeFloat(v)
+		}
+	}
+
+	writeFloat(opts.risk.RiskFreeRate)
+	for _, conf := range opts.risk.Confidence {
+		writeFloat(conf)
+	}
+	if opts.indicators == nil {
+		fmt.Fprintf(h, "|default:%d/%d/%d", defaults.MAShort, defaults.MALong, defaults.RSIPeriod)
+	}
+	for _, spec := range opts.indicators {
+		writeSpec(h, spec)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeSpec hashes the key as well as the parameters, since results are
+// keyed by the spec as written
+func writeSpec(h hash.Hash, spec pkg.Spec) {
+	fmt.Fprintf(h, "|%s|%s", spec.Key, spec.Indicator.Name())
+	for _, p := range spec.Params {
+		fmt.Fprintf(h, "/%v", p)
+	}
+}
+
+// metricETag identifies a /metric response. The ticker is part of it since
+// the response carries that ticker's prediction job.
+func metricETag(key, ticker string) string {
+	sum := sha256.Sum256([]byte(key + "|" + ticker))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches implements the weak comparison of If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// resultsSize approximates the memory held by cached results: every series
+// is a float per bar
+func resultsSize(r *MetricResults, bars int) int64 {
+	lines := 6 + 3*3
+	if r.Indicators != nil {
+		lines = 0
+		for _, v := range r.Indicators {
+			switch v.(type) {
+			case pkg.Line:
+				lines++
+			case pkg.Bands, pkg.MACDLines:
+				lines += 3
+			}
+		}
+	}
+	// The dates count as one more series
+	return int64((lines + 1) * bars * 8)
+}
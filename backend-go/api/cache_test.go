@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache[int](2, 100, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.add("a", 1, 10)
+	c.add("b", 2, 10)
+	_, ok := c.get("a")
+	require.True(t, ok)
+
+	// b is the least recently used
+	c.add("c", 3, 10)
+	_, ok = c.get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.len())
+
+	// The byte budget evicts too, an entry over the whole budget is skipped
+	c.add("d", 4, 95)
+	assert.Equal(t, 1, c.len())
+	c.add("huge", 5, 101)
+	v, ok := c.get("d")
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+
+	now = now.Add(time.Minute)
+	_, ok = c.get("d")
+	assert.False(t, ok, "entries expire after the TTL")
+	assert.Equal(t, 0, c.len())
+
+	// A zero entry limit disables the cache
+	var disabled *lruCache[int] = newLRUCache[int](0, 0, time.Minute)
+	disabled.add("a", 1, 1)
+	_, ok = disabled.get("a")
+	assert.False(t, ok)
+}
+
+func TestEtagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"x", W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(``, `"abc"`))
+	assert.False(t, etagMatches(`"abd"`, `"abc"`))
+}
+
+func TestHandler_Metric_ETagAndCache(t *testing.T) {
+	handler, router := setupTest()
+	csvData := "Date,Close\n2023-01-01,100\n2023-01-02,101\n2023-01-03,99"
+	post := func(fields map[string]string, ifNoneMatch string) *httptest.ResponseRecorder {
+		fields["ticker"] = "AAPL"
+		body, contentType, err := createMultipartFormWithFields(csvData, fields)
+		require.NoError(t, err)
+		req, _ := http.NewRequest("POST", "/metric", body)
+		req.Header.Set("Content-Type", contentType)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post(map[string]string{}, "")
+	require.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, 1, handler.metricCache.len())
+
+	// The same upload again is not modified
+	w := post(map[string]string{}, etag)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// Other options are another cache entry and another ETag
+	w = post(map[string]string{"indicators": "rsi:2"}, etag)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, 2, handler.metricCache.len())
+
+	// Without If-None-Match the cached results are served with a new job
+	w = post(map[string]string{}, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, first.Body.String()[:50], w.Body.String()[:50])
+	assert.Equal(t, 2, handler.metricCache.len())
+}
+
+func TestHandler_Metric_NotModifiedJob(t *testing.T) {
+	// No workers, so jobs stay queued until the test ends them
+	handler := newTestHandler(testConfig(0))
+	t.Cleanup(func() { handler.Shutdown(context.Background()) })
+	router := gin.New()
+	router.POST("/metric", handler.Metric)
+	post := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		body, contentType, err := createMultipartForm("Date,Close\n2023-01-01,100\n2023-01-02,101\n", "AAPL")
+		require.NoError(t, err)
+		req, _ := http.NewRequest("POST", "/metric", body)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("")
+	require.Equal(t, http.StatusOK, first.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(first.Body.Bytes(), &resp))
+	jobID := first.Header().Get(JobIDHeader)
+	assert.Equal(t, resp["job_id"], jobID)
+	etag := first.Header().Get("ETag")
+
+	// Revalidating points at the job of the earlier response
+	w := post(etag)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	assert.Equal(t, jobID, w.Header().Get(JobIDHeader))
+
+	// Once that job is cancelled a new one is queued
+	require.True(t, handler.predictionService.Cancel(jobID))
+	w = post(etag)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	newID := w.Header().Get(JobIDHeader)
+	assert.NotEmpty(t, newID)
+	assert.NotEqual(t, jobID, newID)
+	_, ok := handler.jobs.Get(newID)
+	assert.True(t, ok)
+}
+
+func TestPredictionService_DeduplicatesInFlight(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		calls.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		w.Write([]byte(`{"predictions":[1],"y_test":[1],"dates":["2025-07-20"]}`))
+	}))
+	defer fakeServer.Close()
+
+	jobs := NewMemoryJobStore(time.Minute)
+	ps := NewPredictionService(testConfig(2), jobs)
+	ps.ml = NewMLClient(fakeServer.URL, testMLClientConfig())
+	defer ps.queue.close()
+
+	submit := func() (string, <-chan PredictionResponse) {
+		job, err := jobs.Create("AAPL")
+		require.NoError(t, err)
+		return job.ID, ps.Submit(PredictionRequest{JobID: job.ID, FileData: []byte("Close\n1\n2\n"), FileName: "a.csv"})
+	}
+	leaderID, leaderCh := submit()
+	_, followerCh := submit()
+	cancelledID, cancelledCh := submit()
+
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, 5*time.Second, time.Millisecond)
+
+	// A follower can leave without affecting the others
+	require.True(t, ps.Cancel(cancelledID))
+	assert.Equal(t, "cancelled", (<-cancelledCh).Status)
+
+	// A cancelled leader hands the call over to a follower
+	require.True(t, ps.Cancel(leaderID))
+	assert.Equal(t, "cancelled", (<-leaderCh).Status)
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, 5*time.Second, time.Millisecond)
+
+	close(release)
+	select {
+	case resp := <-followerCh:
+		assert.Equal(t, "success", resp.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the follower")
+	}
+
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/Samudra-G/stockprediction-refactored/forecast"
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+// fallbackPrediction scores the native baseline models on the upload when the
+// ML backend cannot serve it. The response keeps the FastAPI shape so the
+// frontend renders it unchanged, labeled with the model that produced it.
+func (ps *PredictionService) fallbackPrediction(req PredictionRequest, cause error) PredictionResponse {
+	series := req.Series
+	if series == nil {
+		parsed, err := pkg.ParseCSV(bytes.NewReader(req.FileData), pkg.DefaultColumns())
+		if err != nil {
+			return PredictionResponse{Status: "failed", Error: fmt.Errorf("%v; fallback: %w", cause, err)}
+		}
+		series = parsed
+	}
+
+	closes := series.Closes()
+	// Same 80/20 split the LSTM service uses, model selection only sees the
+	// training part
+	trainLen := int(math.Ceil(float64(len(closes)) * 0.8))
+	model, err := forecast.Select(ps.fallbacks, closes[:trainLen], int(math.Ceil(float64(trainLen)*0.8)))
+	if err != nil {
+		return PredictionResponse{Status: "failed", Error: fmt.Errorf("%v; fallback: %w", cause, err)}
+	}
+
+	predictions, err := forecast.WalkForward(model, closes, trainLen)
+	if err != nil {
+		return PredictionResponse{Status: "failed", Error: fmt.Errorf("%v; fallback: %w", cause, err)}
+	}
+
+	dates := seriesDates(series)
+	if dates != nil {
+		dates = dates[trainLen:]
+	}
+
+	return PredictionResponse{
+		Status: "success",
+		Data: &Prediction{
+			Predictions:    predictions,
+			YTest:          closes[trainLen:],
+			Dates:          dates,
+			Model:          model.Name(),
+			Source:         "fallback",
+			FallbackReason: errorString(cause),
+		},
+	}
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -2,18 +2,20 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
 	"strconv"
-	"sync"
+	"strings"
 	"time"
 
+	"github.com/Samudra-G/stockprediction-refactored/config"
 	"github.com/Samudra-G/stockprediction-refactored/pkg"
+	"github.com/Samudra-G/stockprediction-refactored/store"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/sync/errgroup"
 )
@@ -22,261 +24,434 @@ import (
 
 type Handler struct {
 	predictionService *PredictionService
+	jobs              JobStore
+	streamHeartbeat   time.Duration
+	columns           pkg.ColumnMapping
+	indicators        config.Indicators
+	defaults          defaultSpecs
+	mlProbe           *healthProbe
+	live              *liveStore
+	// series is nil when the store is disabled
+	series      *store.Store
+	metricCache *lruCache[*MetricResults]
+	// etagJobs is the prediction job sent with each ETag, so a 304 can
+	// point at it
+	etagJobs *lruCache[string]
+	batch    config.Batch
 }
 
-func NewHandler() *Handler {
-	return &Handler{
-		predictionService: NewPredictionService(3), // 3 workers for FastAPI calls
+// NewHandler starts the prediction workers, it fails when the configured
+// default indicators cannot be computed
+func NewHandler(cfg config.Config) (*Handler, error) {
+	defaults, err := newDefaultSpecs(cfg.Indicators)
+	if err != nil {
+		return nil, err
 	}
-}
-
-// PredictionRequest represents a prediction request
-type PredictionRequest struct {
-	FileData   []byte
-	FileName   string
-	ResponseCh chan PredictionResponse
-}
-
-// PredictionResponse represents a prediction response
-type PredictionResponse struct {
-	Status string      `json:"status"`
-	Data   interface{} `json:"data"`
-	Error  error       `json:"error,omitempty"`
-}
-
-// PredictionService handles prediction requests using channels
-type PredictionService struct {
-	requestCh chan PredictionRequest
-	workers   int
-}
-
-func NewPredictionService(workers int) *PredictionService {
-	ps := &PredictionService{
-		requestCh: make(chan PredictionRequest, 10), // buffered channel
-		workers:   workers,
+	jobs := NewMemoryJobStore(cfg.Predictions.JobTTL)
+	h := &Handler{
+		predictionService: NewPredictionService(cfg, jobs),
+		jobs:              jobs,
+		streamHeartbeat:   cfg.StreamHeartbeat,
+		columns:           pkg.ColumnMapping(cfg.Columns),
+		indicators:        cfg.Indicators,
+		defaults:          defaults,
+		live:              newLiveStore(cfg.Indicators),
+		metricCache: newLRUCache[*MetricResults](cfg.MetricCache.MaxEntries,
+			int64(cfg.MetricCache.MaxBytes), cfg.MetricCache.TTL),
+		etagJobs: newLRUCache[string](cfg.MetricCache.MaxEntries, 0, cfg.Predictions.JobTTL),
+		batch:    cfg.Batch,
 	}
-
-	// Start worker pool
-	for i := 0; i < workers; i++ {
-		go ps.worker()
+	if cfg.Store.Dir != "" {
+		h.series = store.New(cfg.Store.Dir)
+		h.live.load = func(ticker string) (*pkg.Series, error) {
+			return h.series.Range(ticker, time.Time{}, time.Time{})
+		}
 	}
-
-	return ps
+	h.mlProbe = newHealthProbe(func(ctx context.Context) error {
+		return h.predictionService.ml.Health(ctx)
+	}, cfg.Readiness.MLCacheTTL, cfg.Readiness.ProbeTimeout)
+	return h, nil
 }
 
-func (ps *PredictionService) worker() {
-	for req := range ps.requestCh {
-		result := ps.callFastAPI(req.FileData, req.FileName)
-		req.ResponseCh <- result
-		close(req.ResponseCh)
-	}
+// Shutdown stops queueing prediction jobs and waits for the workers to drain
+// the queue, see PredictionService.Shutdown
+func (h *Handler) Shutdown(ctx context.Context) error {
+	return h.predictionService.Shutdown(ctx)
 }
 
-func (ps *PredictionService) callFastAPI(fileData []byte, fileName string) PredictionResponse {
-	bodyBuf := &bytes.Buffer{}
-	writer := multipart.NewWriter(bodyBuf)
-
-	part, err := writer.CreateFormFile("file", fileName)
-	if err != nil {
-		log.Println("Failed to create form file:", err)
-		return PredictionResponse{Status: "failed", Error: err}
-	}
+// MetricResults holds all calculated metrics. Every series is aligned to
+// Dates, bars before an indicator's warm-up completes are null. The moving
+// averages keep their ma100/ma200 keys whatever windows are configured.
+// When the request selects indicators only those are computed, keyed by
+// their spec in Indicators, and the default series are left out.
+type MetricResults struct {
+	Dates      []string       `json:"dates"`
+	MAShort    pkg.Line       `json:"ma100,omitempty"`
+	MALong     pkg.Line       `json:"ma200,omitempty"`
+	RSI        pkg.Line       `json:"rsi,omitempty"`
+	Volatility pkg.Number     `json:"volatility"`
+	MACD       pkg.Line       `json:"macd,omitempty"`
+	Signal     pkg.Line       `json:"signal,omitempty"`
+	Histogram  pkg.Line       `json:"histogram,omitempty"`
+	Bollinger  *pkg.Bands     `json:"bollinger,omitempty"`
+	Keltner    *pkg.Bands     `json:"keltner,omitempty"`
+	Donchian   *pkg.Bands     `json:"donchian,omitempty"`
+	Indicators map[string]any `json:"indicators,omitempty"`
+	Risk       pkg.RiskReport `json:"risk"`
+	JobID      string         `json:"job_id"`
+}
 
-	_, err = part.Write(fileData)
-	if err != nil {
-		log.Println("Failed to write file contents:", err)
-		return PredictionResponse{Status: "failed", Error: err}
-	}
+// metricOptions are the optional /metric form fields
+type metricOptions struct {
+	risk     pkg.RiskConfig
+	priority Priority
+	// indicators is nil when the default set is wanted
+	indicators []pkg.Spec
+}
 
-	writer.Close()
+// parseMetricOptions reads risk_free_rate and a comma separated confidence
+// list, e.g. confidence=0.95,0.99
+func parseMetricOptions(c *gin.Context) (metricOptions, error) {
+	opts := metricOptions{risk: pkg.DefaultRiskConfig()}
 
-	mlBackendURL := os.Getenv("ML_BACKEND")
-	if mlBackendURL == "" {
-		log.Println("ML_BACKEND environment variable is not set")
-		return PredictionResponse{Status: "failed", Error: err}
+	if raw := c.PostForm("risk_free_rate"); raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid risk_free_rate: %q", raw)
+		}
+		opts.risk.RiskFreeRate = rate
 	}
-
-	req, err := http.NewRequest("POST", mlBackendURL+"/api/v1/predict", bodyBuf)
-	if err != nil {
-		log.Println("Failed to create FastAPI request:", err)
-		return PredictionResponse{Status: "failed", Error: err}
+	if raw := c.PostForm("confidence"); raw != "" {
+		opts.risk.Confidence = nil
+		for _, part := range strings.Split(raw, ",") {
+			conf, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return opts, fmt.Errorf("invalid confidence: %q", part)
+			}
+			opts.risk.Confidence = append(opts.risk.Confidence, conf)
+		}
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	client := &http.Client{
-		Timeout: 60 * time.Second,
+	if err := opts.risk.Validate(); err != nil {
+		return opts, err
 	}
 
-	resp, err := client.Do(req)
+	priority, err := ParsePriority(c.PostForm("priority"))
 	if err != nil {
-		log.Println("FastAPI request failed:", err)
-		return PredictionResponse{Status: "failed", Error: err}
+		return opts, err
 	}
-	defer resp.Body.Close()
+	opts.priority = priority
 
-	if resp.StatusCode != http.StatusOK {
-		log.Println("FastAPI returned bad status:", resp.Status)
-		return PredictionResponse{Status: "failed", Error: err}
-	}
-
-	var result map[string]interface{}
-	if err := pkg.FromJSON(resp.Body, &result); err != nil {
-		log.Println("Failed to decode FastAPI response:", err)
-		return PredictionResponse{Status: "failed", Error: err}
+	if raw := c.PostForm("indicators"); strings.TrimSpace(raw) != "" {
+		if opts.indicators, err = parseIndicatorSpecs(raw); err != nil {
+			return opts, err
+		}
 	}
-
-	return PredictionResponse{Status: "success", Data: result}
+	return opts, nil
 }
 
-// RequestPrediction submits a prediction request and returns a response channel
-func (ps *PredictionService) RequestPrediction(fileData []byte, fileName string) <-chan PredictionResponse {
-	responseCh := make(chan PredictionResponse, 1)
-	
-	select {
-	case ps.requestCh <- PredictionRequest{
-		FileData:   fileData,
-		FileName:   fileName,
-		ResponseCh: responseCh,
-	}:
-		return responseCh
-	default:
-		// Channel is full, reject request
-		go func() {
-			responseCh <- PredictionResponse{
-				Status: "failed",
-				Error:  fmt.Errorf("prediction service busy, try again later"),
-			}
-			close(responseCh)
-		}()
-		return responseCh
+// clientID identifies the caller for fair scheduling, an explicit
+// X-Client-ID header wins over the remote address
+func clientID(c *gin.Context) string {
+	if id := strings.TrimSpace(c.GetHeader("X-Client-ID")); id != "" {
+		return id
 	}
-}
-
-// MetricResults holds all calculated metrics
-type MetricResults struct {
-	MA100      []float64   `json:"ma100"`
-	MA200      []float64   `json:"ma200"`
-	RSI        []float64   `json:"rsi"`
-	Volatility float64     `json:"volatility"`
-	MACD       []float64   `json:"macd"`
-	Signal     []float64   `json:"signal"`
-	Histogram  []float64   `json:"histogram"`
-	PredictionCh <-chan PredictionResponse `json:"-"`
+	return c.ClientIP()
 }
 
 func (h *Handler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "Go Backend running..."})
 }
 
+// JobIDHeader carries the prediction job of a /metric response. A 304 has no
+// body, the header is then the job of the earlier response, or a new one if
+// that job is gone or did not succeed.
+const JobIDHeader = "X-Job-ID"
+
 func (h *Handler) Metric(c *gin.Context) {
+	if !h.predictionService.Accepting() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrShuttingDown.Error()})
+		return
+	}
+
+	logger := loggerFrom(c.Request.Context())
+
 	file, err := c.FormFile("file")
 	if err != nil {
-		log.Println("Failed to get form file:", err)
+		logger.Warn("Failed to get form file", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required"})
 		return
 	}
 
 	ticker := c.PostForm("ticker")
 	if ticker == "" {
-		log.Println("Ticker not provided")
+		logger.Warn("Ticker not provided")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Ticker is required"})
 		return
 	}
 
-	// Parse CSV and calculate metrics concurrently
-	results, err := h.processMetrics(file)
+	opts, err := parseMetricOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	series, fileData, err := h.parseCSV(c.Request.Context(), file)
 	if err != nil {
-		log.Println("Failed to process metrics:", err)
+		logger.Warn("Failed to parse CSV", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Store prediction channel for polling (you might want to use Redis here later)
-	// For now, we'll still use a simple in-memory store but with better structure
-	h.storePredictionChannel(ticker, results.PredictionCh)
+	// An unchanged upload needs no new metrics, and keeps the prediction job
+	// it was sent with
+	key := metricCacheKey(series, opts, h.indicators)
+	etag := metricETag(key, ticker)
+	notModified := etagMatches(c.GetHeader("If-None-Match"), etag)
+	jobID, reused := "", false
+	if notModified {
+		jobID, reused = h.etagJob(etag)
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"ma100":      results.MA100,
-		"ma200":      results.MA200,
-		"rsi":        results.RSI,
-		"volatility": results.Volatility,
-		"macd":       results.MACD,
-		"signal":     results.Signal,
-		"histogram":  results.Histogram,
-	})
+	// Calculate metrics concurrently and queue the prediction job
+	var results *MetricResults
+	if !notModified {
+		results, err = h.cachedMetrics(key, series, opts)
+		if err != nil {
+			logger.Error("Failed to process metrics", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		// Later bars can be appended to the stored history through
+		// /series/:ticker/bars, which only takes tickers the store can hold
+		if stored, err := store.NormalizeTicker(ticker); err == nil {
+			h.live.reset(stored, h.storeSeries(c.Request.Context(), stored, series))
+		}
+	}
+	if !reused {
+		jobID, err = h.queuePrediction(PredictionRequest{
+			FileData:  fileData,
+			FileName:  file.Filename,
+			Series:    series,
+			Priority:  opts.priority,
+			ClientID:  clientID(c),
+			RequestID: requestID(c),
+		}, ticker)
+		if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrClientQueueFull) {
+			// Tell the caller now rather than on the next poll
+			retryAfter := int(h.predictionService.RetryAfter().Seconds())
+			logger.Warn("Prediction queue full", "error", err, "retry_after", retryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "retry_after": retryAfter})
+			return
+		}
+		if errors.Is(err, ErrShuttingDown) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to queue prediction", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		h.etagJobs.add(etag, jobID, 1)
+	}
+
+	c.Header("ETag", etag)
+	c.Header(JobIDHeader, jobID)
+	if notModified {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	results.JobID = jobID
+	c.JSON(http.StatusOK, results)
 }
 
-func (h *Handler) processMetrics(file *multipart.FileHeader) (*MetricResults, error) {
-	// Read and parse CSV
-	closes, fileData, err := h.parseCSV(file)
+// etagJob is the job sent with an earlier response carrying etag, unless it
+// has expired, failed or been cancelled since
+func (h *Handler) etagJob(etag string) (string, bool) {
+	id, ok := h.etagJobs.get(etag)
+	if !ok {
+		return "", false
+	}
+	job, ok := h.jobs.Get(id)
+	if !ok || job.Status == JobFailed || job.Status == JobCancelled {
+		return "", false
+	}
+	return id, true
+}
+
+// cachedMetrics is processMetrics behind the metric cache, the returned
+// results are a copy the caller may modify
+func (h *Handler) cachedMetrics(key string, series *pkg.Series, opts metricOptions) (*MetricResults, error) {
+	if cached, ok := h.metricCache.get(key); ok {
+		metricCacheRequests.WithLabelValues("hit").Inc()
+		results := *cached
+		return &results, nil
+	}
+	metricCacheRequests.WithLabelValues("miss").Inc()
+
+	results, err := h.processMetrics(series, opts)
 	if err != nil {
 		return nil, err
 	}
+	stored := *results
+	h.metricCache.add(key, &stored, resultsSize(results, series.Len()))
+	return results, nil
+}
 
-	if len(closes) < 2 {
+// processMetrics computes the requested indicators over the parsed series,
+// or the default set when the request did not select any
+func (h *Handler) processMetrics(series *pkg.Series, opts metricOptions) (*MetricResults, error) {
+	if series.Len() < 2 {
 		return nil, fmt.Errorf("not enough close prices")
 	}
 
-	// Create errgroup for concurrent metric calculations
-	g := &errgroup.Group{}
-	
+	selected := opts.indicators != nil
+	defaults := h.defaults
+	specs := opts.indicators
+	if !selected {
+		specs = defaults.specs()
+	}
+	// Volatility is always part of the summary
+	specs = append(specs[:len(specs):len(specs)], defaults.vol)
+
 	var (
-		ma100     []float64
-		ma200     []float64
-		rsi       []float64
-		vol       float64
-		macdLine  []float64
-		signalLine []float64
-		histogram []float64
+		values []any
+		risk   pkg.RiskReport
 	)
-
-	// Calculate metrics concurrently
+	g := &errgroup.Group{}
 	g.Go(func() error {
-		ma100 = pkg.MovingAverage(closes, 100)
+		values = computeIndicators(series, specs)
 		return nil
 	})
-
 	g.Go(func() error {
-		ma200 = pkg.MovingAverage(closes, 200)
+		risk = pkg.Risk(series.Closes(), series.Times(), opts.risk)
 		return nil
 	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
 
-	g.Go(func() error {
-		rsi = pkg.RSI(closes, 14)
-		return nil
-	})
+	byKey := make(map[string]any, len(specs))
+	for i, spec := range specs {
+		byKey[spec.Key] = values[i]
+	}
+	results := &MetricResults{
+		Dates: seriesDates(series),
+		Risk:  risk,
+	}
+	results.Volatility, _ = byKey[defaults.vol.Key].(pkg.Number)
+	if selected {
+		results.Indicators = make(map[string]any, len(opts.indicators))
+		for _, spec := range opts.indicators {
+			results.Indicators[spec.Key] = byKey[spec.Key]
+		}
+		return results, nil
+	}
 
-	g.Go(func() error {
-		vol = pkg.Volatility(closes)
+	// The default set keeps its fixed response keys
+	line := func(spec pkg.Spec) pkg.Line {
+		v, _ := byKey[spec.Key].(pkg.Line)
+		return v
+	}
+	bands := func(spec pkg.Spec) *pkg.Bands {
+		if v, ok := byKey[spec.Key].(pkg.Bands); ok {
+			return &v
+		}
 		return nil
-	})
+	}
+	results.MAShort = line(defaults.maShort)
+	results.MALong = line(defaults.maLong)
+	results.RSI = line(defaults.rsi)
+	if macd, ok := byKey[defaults.macd.Key].(pkg.MACDLines); ok {
+		results.MACD, results.Signal, results.Histogram = macd.MACD, macd.Signal, macd.Histogram
+	}
+	results.Bollinger = bands(defaults.bollinger)
+	results.Keltner = bands(defaults.keltner)
+	results.Donchian = bands(defaults.donchian)
+	return results, nil
+}
 
-	g.Go(func() error {
-		macdLine, signalLine, histogram = pkg.MACD(closes)
+// defaultSpecs is the set computed when a request selects none, one spec
+// per fixed field of MetricResults, and the volatility every request gets
+type defaultSpecs struct {
+	maShort, maLong, rsi, macd   pkg.Spec
+	bollinger, keltner, donchian pkg.Spec
+	vol                          pkg.Spec
+}
+
+func (d defaultSpecs) specs() []pkg.Spec {
+	return []pkg.Spec{d.maShort, d.maLong, d.rsi, d.macd, d.bollinger, d.keltner, d.donchian}
+}
+
+// newDefaultSpecs parses the default set once, the configured windows may
+// be out of the range the indicators accept
+func newDefaultSpecs(ind config.Indicators) (defaultSpecs, error) {
+	var errs []error
+	parse := func(format string, args ...any) pkg.Spec {
+		spec, err := pkg.Indicators.ParseSpec(fmt.Sprintf(format, args...))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("default indicators: %w", err))
+		}
+		return spec
+	}
+	d := defaultSpecs{
+		maShort:   parse("ma:%d", ind.MAShort),
+		maLong:    parse("ma:%d", ind.MALong),
+		rsi:       parse("rsi:%d", ind.RSIPeriod),
+		macd:      parse("macd:12/26/9"),
+		bollinger: parse("bb:20/2"),
+		keltner:   parse("keltner:20/10/2"),
+		donchian:  parse("donchian:20"),
+		vol:       parse("vol"),
+	}
+	return d, errors.Join(errs...)
+}
+
+// seriesDates formats the bar timestamps as the shared axis of the response,
+// it is nil when the upload had no date column
+func seriesDates(series *pkg.Series) []string {
+	if series.Len() == 0 || series.Bars[0].Time.IsZero() {
 		return nil
+	}
+	dates := make([]string, series.Len())
+	for i, bar := range series.Bars {
+		dates[i] = bar.Time.Format("2006-01-02")
+	}
+	return dates
+}
+
+// queuePrediction creates a job for the upload and hands it to the worker pool
+func (h *Handler) queuePrediction(req PredictionRequest, ticker string) (string, error) {
+	return h.submitPrediction(req, ticker, h.predictionService.Enqueue)
+}
+
+// queuePredictionWait is queuePrediction that waits for room in the queue
+// until ctx is done, so every ticker of a batch gets a job however small the
+// client's share of the queue
+func (h *Handler) queuePredictionWait(ctx context.Context, req PredictionRequest, ticker string) (string, error) {
+	return h.submitPrediction(req, ticker, func(req PredictionRequest) (<-chan PredictionResponse, error) {
+		return h.predictionService.EnqueueWait(ctx, req)
 	})
+}
 
-	// Wait for all metric calculations to complete
-	if err := g.Wait(); err != nil {
-		return nil, err
+func (h *Handler) submitPrediction(req PredictionRequest, ticker string,
+	enqueue func(PredictionRequest) (<-chan PredictionResponse, error)) (string, error) {
+	job, err := h.jobs.Create(ticker)
+	if err != nil {
+		return "", err
 	}
 
-	// Start prediction request (non-blocking)
-	predictionCh := h.predictionService.RequestPrediction(fileData, file.Filename)
-
-	return &MetricResults{
-		MA100:        ma100,
-		MA200:        ma200,
-		RSI:          rsi,
-		Volatility:   vol,
-		MACD:         macdLine,
-		Signal:       signalLine,
-		Histogram:    histogram,
-		PredictionCh: predictionCh,
-	}, nil
+	// Start prediction request (non-blocking), the outcome lands in the job store
+	req.JobID = job.ID
+	req.FileData = h.mlFile(req.FileData, req.Series)
+	if _, err := enqueue(req); err != nil {
+		return "", err
+	}
+	return job.ID, nil
 }
 
-func (h *Handler) parseCSV(file *multipart.FileHeader) ([]float64, []byte, error) {
+func (h *Handler) parseCSV(ctx context.Context, file *multipart.FileHeader) (*pkg.Series, []byte, error) {
 	f, err := file.Open()
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to open uploaded file: %w", err)
@@ -289,77 +464,73 @@ func (h *Handler) parseCSV(file *multipart.FileHeader) ([]float64, []byte, error
 		return nil, nil, fmt.Errorf("failed to read file data: %w", err)
 	}
 
-	// Reset reader for CSV parsing
-	f.Seek(0, 0)
-	reader := csv.NewReader(f)
-
-	// Reading header
-	headers, err := reader.Read()
+	series, err := h.parseCSVData(ctx, fileData)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
+		return nil, nil, err
 	}
+	return series, fileData, nil
+}
 
-	// Find "Close" column
-	closeIdx := -1
-	for i, col := range headers {
-		if col == "Close" {
-			closeIdx = i
-			break
-		}
+// parseCSVData parses an upload already read into memory
+func (h *Handler) parseCSVData(ctx context.Context, fileData []byte) (*pkg.Series, error) {
+	start := time.Now()
+	defer func() { csvParseDuration.Observe(time.Since(start).Seconds()) }()
+
+	series, err := pkg.ParseCSV(bytes.NewReader(fileData), h.columns)
+	if err != nil {
+		return nil, err
 	}
-	if closeIdx == -1 {
-		return nil, nil, fmt.Errorf(`"Close" column not found in CSV headers`)
+	csvRows.WithLabelValues("parsed").Add(float64(series.Len()))
+	csvRows.WithLabelValues("skipped").Add(float64(series.Skipped))
+	if series.Skipped > 0 {
+		loggerFrom(ctx).Info("Skipped unparsable CSV rows", "skipped", series.Skipped, "rows", series.Len())
 	}
+	return series, nil
+}
 
-	var closes []float64
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, nil, fmt.Errorf("error reading CSV row: %w", err)
-		}
-
-		val, err := strconv.ParseFloat(record[closeIdx], 64)
-		if err != nil {
-			log.Println("Failed to parse float value:", record[closeIdx], err)
-			continue
+// mlFile is the upload as the ML backend reads it, which expects the default
+// headers. An upload read through other configured columns is written back
+// out from its parsed bars.
+func (h *Handler) mlFile(fileData []byte, series *pkg.Series) []byte {
+	if series == nil || h.columns == pkg.DefaultColumns() {
+		return fileData
+	}
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Write([]string{"Date", "Open", "High", "Low", "Close", "Adj Close", "Volume"})
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for _, bar := range series.Bars {
+		date := ""
+		if !bar.Time.IsZero() {
+			date = bar.Time.Format("2006-01-02")
 		}
-
-		closes = append(closes, val)
+		w.Write([]string{
+			date, format(bar.Open), format(bar.High), format(bar.Low),
+			format(bar.Close), format(bar.AdjClose), format(bar.Volume),
+		})
 	}
-
-	return closes, fileData, nil
-}
-
-// Simple in-memory store for prediction channels (replace with Redis later)
-var (
-	predictionChannels = make(map[string]<-chan PredictionResponse)
-	channelMutex       = &sync.RWMutex{}
-)
-
-func (h *Handler) storePredictionChannel(ticker string, ch <-chan PredictionResponse) {
-	channelMutex.Lock()
-	defer channelMutex.Unlock()
-	predictionChannels[ticker] = ch
-}
-
-func (h *Handler) getPredictionChannel(ticker string) (<-chan PredictionResponse, bool) {
-	channelMutex.RLock()
-	defer channelMutex.RUnlock()
-	ch, exists := predictionChannels[ticker]
-	return ch, exists
+	w.Flush()
+	return buf.Bytes()
 }
 
+// Poll reports the prediction job for a job_id, or the latest job for a ticker
 func (h *Handler) Poll(c *gin.Context) {
+	jobID := c.Query("job_id")
 	ticker := c.Query("ticker")
-	if ticker == "" {
+	if jobID == "" && ticker == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ticker parameter required"})
 		return
 	}
 
-	ch, exists := h.getPredictionChannel(ticker)
+	var (
+		job    Job
+		exists bool
+	)
+	if jobID != "" {
+		job, exists = h.jobs.Get(jobID)
+	} else {
+		job, exists = h.jobs.Latest(ticker)
+	}
 	if !exists {
 		c.JSON(http.StatusOK, gin.H{
 			"status":      "idle",
@@ -368,31 +539,77 @@ func (h *Handler) Poll(c *gin.Context) {
 		return
 	}
 
-	// Non-blocking check for prediction result
-	select {
-	case result := <-ch:
-		// Remove from store after consuming
-		channelMutex.Lock()
-		delete(predictionChannels, ticker)
-		channelMutex.Unlock()
-
-		if result.Error != nil {
-			c.JSON(http.StatusOK, gin.H{
-				"status":      "failed",
-				"predictions": nil,
-				"error":       result.Error.Error(),
-			})
-		} else {
-			c.JSON(http.StatusOK, gin.H{
-				"status":      result.Status,
-				"predictions": result.Data,
-			})
+	switch job.Status {
+	case JobSucceeded:
+		c.JSON(http.StatusOK, gin.H{
+			"status":      "success",
+			"job_id":      job.ID,
+			"predictions": job.Result,
+			"evaluation":  job.Result.Evaluation,
+		})
+	case JobFailed:
+		response := gin.H{
+			"status":      "failed",
+			"job_id":      job.ID,
+			"predictions": nil,
+			"error":       job.Error,
+		}
+		if job.UpstreamStatus != 0 {
+			response["upstream_status"] = job.UpstreamStatus
 		}
+		c.JSON(http.StatusOK, response)
+	case JobCancelled:
+		c.JSON(http.StatusOK, gin.H{
+			"status":      "cancelled",
+			"job_id":      job.ID,
+			"predictions": nil,
+		})
 	default:
-		// Still pending
+		// Still queued or running
 		c.JSON(http.StatusOK, gin.H{
 			"status":      "pending",
+			"job_id":      job.ID,
 			"predictions": nil,
 		})
 	}
-}
\ No newline at end of file
+}
+
+// Job returns the full record of a prediction job
+func (h *Handler) Job(c *gin.Context) {
+	job, exists := h.jobs.Get(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob cancels a queued or running prediction job
+func (h *Handler) CancelJob(c *gin.Context) {
+	id := c.Param("id")
+	job, exists := h.jobs.Get(id)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if job.Status.Done() {
+		c.JSON(http.StatusConflict, gin.H{"error": "job already finished", "status": job.Status})
+		return
+	}
+
+	if !h.predictionService.Cancel(id) {
+		// Not handed to the worker pool (or it just finished), the store
+		// keeps whichever terminal state lands first
+		if err := h.jobs.Complete(id, PredictionResponse{Status: "cancelled", Error: ErrJobCancelled}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	job, _ = h.jobs.Get(id)
+	if job.Status != JobCancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "job already finished", "status": job.Status})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -2,8 +2,11 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +14,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Samudra-G/stockprediction-refactored/config"
+	"github.com/Samudra-G/stockprediction-refactored/forecast"
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,24 +25,64 @@ import (
 // Setup test environment
 func setupTest() (*Handler, *gin.Engine) {
 	gin.SetMode(gin.TestMode)
-	handler := NewHandler()
+	cfg := config.Default()
+	// The integration test talks to a real backend when one is configured
+	cfg.ML.URL = os.Getenv("ML_BACKEND")
+	cfg.Store.Dir = ""
+	handler := newTestHandler(cfg)
 	router := gin.New()
+	router.Use(Instrument())
 	
 	router.GET("/health", handler.Health)
+	router.GET("/ready", handler.Ready)
+	router.GET("/metrics", handler.Prometheus)
 	router.POST("/metric", handler.Metric)
+	router.POST("/metric/batch", handler.MetricBatch)
+	router.POST("/backtest", handler.Backtest)
 	router.GET("/poll", handler.Poll)
+	router.GET("/poll/stream", handler.PollStream)
+	router.GET("/jobs/:id", handler.Job)
+	router.DELETE("/jobs/:id", handler.CancelJob)
+	router.GET("/series/:ticker", handler.Series)
+	router.POST("/series/:ticker/bars", handler.AppendBars)
+	router.POST("/screen", handler.Screen)
 	
 	return handler, router
 }
 
+// newTestHandler is NewHandler for a configuration the test knows is valid
+func newTestHandler(cfg config.Config) *Handler {
+	handler, err := NewHandler(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
+
+// testConfig is the default configuration with the given number of workers
+func testConfig(workers int) config.Config {
+	cfg := config.Default()
+	cfg.Predictions.Workers = workers
+	cfg.Store.Dir = ""
+	return cfg
+}
+
 // Create multipart form with CSV file
 func createMultipartForm(csvData, ticker string) (*bytes.Buffer, string, error) {
+	fields := map[string]string{}
+	if ticker != "" {
+		fields["ticker"] = ticker
+	}
+	return createMultipartFormWithFields(csvData, fields)
+}
+
+// Create multipart form with CSV file and arbitrary form fields
+func createMultipartFormWithFields(csvData string, fields map[string]string) (*bytes.Buffer, string, error) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
-	// Add ticker field
-	if ticker != "" {
-		err := writer.WriteField("ticker", ticker)
+	for key, value := range fields {
+		err := writer.WriteField(key, value)
 		if err != nil {
 			return nil, "", err
 		}
@@ -101,7 +147,7 @@ func TestHandler_Metric_Success(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-
+	
 	var response map[string]interface{}
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	require.NoError(t, err)
@@ -114,12 +160,210 @@ func TestHandler_Metric_Success(t *testing.T) {
 	assert.Contains(t, response, "macd")
 	assert.Contains(t, response, "signal")
 	assert.Contains(t, response, "histogram")
+	assert.Contains(t, response, "bollinger")
+	assert.Contains(t, response, "keltner")
+	assert.Contains(t, response, "donchian")
 
 	// Verify the response types
 	assert.IsType(t, []interface{}{}, response["ma100"])
 	assert.IsType(t, []interface{}{}, response["ma200"])
 	assert.IsType(t, []interface{}{}, response["rsi"])
 	assert.IsType(t, float64(0), response["volatility"])
+	assert.NotEmpty(t, response["job_id"])
+
+	// Every series shares the date axis, warm-up bars are null
+	dates, ok := response["dates"].([]interface{})
+	require.True(t, ok, "dates should be a list")
+	assert.Len(t, dates, 250)
+	assert.Equal(t, "2023-01-01", dates[0])
+	for _, key := range []string{"ma100", "ma200", "rsi", "macd", "signal", "histogram"} {
+		assert.Len(t, response[key], 250, key)
+	}
+	ma100 := response["ma100"].([]interface{})
+	assert.Nil(t, ma100[98])
+	assert.NotNil(t, ma100[99])
+
+	bollinger, ok := response["bollinger"].(map[string]interface{})
+	require.True(t, ok, "bollinger should be an object")
+	middle := bollinger["middle"].([]interface{})
+	assert.Len(t, middle, 250)
+	assert.Nil(t, middle[18])
+	assert.NotNil(t, middle[19])
+
+	risk, ok := response["risk"].(map[string]interface{})
+	require.True(t, ok, "risk should be an object")
+	assert.Contains(t, risk, "sharpe")
+	assert.Contains(t, risk, "drawdown")
+	assert.Len(t, risk["var"], 2)
+}
+
+func TestHandler_Metric_ConfiguredColumns(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.Columns.Date, cfg.Columns.High, cfg.Columns.Low, cfg.Columns.Close = "Day", "Hi", "Lo", "Last"
+	handler := newTestHandler(cfg)
+	t.Cleanup(func() { handler.Shutdown(context.Background()) })
+	router := gin.New()
+	router.POST("/metric", handler.Metric)
+
+	post := func(csvData string) *httptest.ResponseRecorder {
+		body, contentType, err := createMultipartFormWithFields(csvData, map[string]string{
+			"ticker":     "AAPL",
+			"indicators": "donchian:2",
+		})
+		require.NoError(t, err)
+		req, _ := http.NewRequest("POST", "/metric", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("day,hi,lo,last\n2023-01-02,12,9,10\n2023-01-03,14,10,11\n2023-01-04,13,8,12\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []interface{}{"2023-01-02", "2023-01-03", "2023-01-04"}, response["dates"])
+	donchian := response["indicators"].(map[string]interface{})["donchian:2"].(map[string]interface{})
+	assert.Equal(t, []interface{}{nil, 14.0, 14.0}, donchian["upper"])
+	assert.Equal(t, []interface{}{nil, 9.0, 8.0}, donchian["lower"])
+
+	// The ML backend gets the upload under the default headers
+	series, err := handler.parseCSVData(context.Background(), []byte("Day,Last\n2023-01-02,10\n2023-01-03,11\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "Date,Open,High,Low,Close,Adj Close,Volume\n2023-01-02,10,10,10,10,10,0\n2023-01-03,11,11,11,11,11,0\n",
+		string(handler.mlFile(nil, series)))
+
+	// The default headers are no longer read
+	w = post("Date,High,Low,Close\n2023-01-02,12,9,10\n2023-01-03,14,10,11\n")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Last")
+}
+
+func TestHandler_Metric_InvalidRiskOptions(t *testing.T) {
+	_, router := setupTest()
+
+	csvData := `Date,Close
+2023-01-01,100
+2023-01-02,101`
+
+	body, contentType, err := createMultipartFormWithFields(csvData, map[string]string{
+		"ticker":     "AAPL",
+		"confidence": "0.95,1.5",
+	})
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/metric", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_Metric_SelectedIndicators(t *testing.T) {
+	_, router := setupTest()
+
+	csvData := "Date,High,Low,Close"
+	for i := 0; i < 60; i++ {
+		day := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, i)
+		csvData += fmt.Sprintf("\n%s,%.1f,%.1f,%.1f", day.Format("2006-01-02"), 105.0+float64(i), 95.0+float64(i), 100.0+float64(i%7))
+	}
+
+	body, contentType, err := createMultipartFormWithFields(csvData, map[string]string{
+		"ticker":     "AAPL",
+		"indicators": "ma:50, ma:20,rsi:7,macd:8/21/5,bb:20/2",
+	})
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/metric", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	// Only the selected indicators are computed
+	assert.NotContains(t, response, "ma100")
+	assert.NotContains(t, response, "bollinger")
+	assert.Contains(t, response, "risk")
+	indicators := response["indicators"].(map[string]interface{})
+	assert.Len(t, indicators, 5)
+
+	ma50 := indicators["ma:50"].([]interface{})
+	assert.Len(t, ma50, 60)
+	assert.Nil(t, ma50[48])
+	assert.NotNil(t, ma50[49])
+	assert.Nil(t, indicators["ma:20"].([]interface{})[18])
+
+	macd := indicators["macd:8/21/5"].(map[string]interface{})
+	signal := macd["signal"].([]interface{})
+	assert.Nil(t, signal[23])
+	assert.NotNil(t, signal[24])
+
+	bb := indicators["bb:20/2"].(map[string]interface{})
+	assert.Len(t, bb["upper"], 60)
+}
+
+func TestProcessMetrics_DefaultsBySpec(t *testing.T) {
+	cfg := testConfig(1)
+	// Equal periods give both averages the same spec key
+	cfg.Indicators.MAShort, cfg.Indicators.MALong = 5, 5
+	handler := newTestHandler(cfg)
+	t.Cleanup(func() { handler.Shutdown(context.Background()) })
+
+	series := &pkg.Series{}
+	for i := 0; i < 40; i++ {
+		c := 100 + float64(i%7)
+		series.Bars = append(series.Bars, pkg.Bar{Open: c, High: c + 1, Low: c - 1, Close: c, AdjClose: c})
+	}
+	results, err := handler.processMetrics(series, metricOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, results.MAShort, 40)
+	assert.True(t, math.IsNaN(results.MAShort[3]))
+	assert.InDelta(t, 102, results.MAShort[4], 1e-9)
+	assert.Equal(t, results.MAShort[4:], results.MALong[4:])
+	assert.Len(t, results.RSI, 40)
+	assert.Len(t, results.Histogram, 40)
+	require.NotNil(t, results.Bollinger)
+	require.NotNil(t, results.Keltner)
+	require.NotNil(t, results.Donchian)
+	assert.Len(t, results.Donchian.Upper, 40)
+	assert.Nil(t, results.Indicators)
+}
+
+func TestNewHandler_InvalidDefaultIndicators(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.Indicators.MALong = pkg.MaxPeriod + 1
+	_, err := NewHandler(cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "default indicators")
+}
+
+func TestHandler_Metric_InvalidIndicators(t *testing.T) {
+	_, router := setupTest()
+
+	body, contentType, err := createMultipartFormWithFields("Close\n1\n2\n3", map[string]string{
+		"ticker":     "AAPL",
+		"indicators": "ma:50,foo:3,rsi:0,macd:26/12",
+	})
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/metric", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	msg := response["error"].(string)
+	assert.Contains(t, msg, `unknown indicator "foo"`)
+	assert.Contains(t, msg, "rsi:0")
+	assert.Contains(t, msg, "macd:26/12")
+	assert.NotContains(t, msg, "ma:50")
 }
 
 func TestHandler_Metric_MissingFile(t *testing.T) {
@@ -249,9 +493,9 @@ func TestHandler_Poll_MissingTicker(t *testing.T) {
 func TestHandler_Poll_PendingPrediction(t *testing.T) {
 	handler, router := setupTest()
 
-	// Create a prediction channel and store it
-	predictionCh := make(chan PredictionResponse, 1)
-	handler.storePredictionChannel("AAPL", predictionCh)
+	// Create a queued job
+	job, err := handler.jobs.Create("AAPL")
+	require.NoError(t, err)
 
 	req, _ := http.NewRequest("GET", "/poll?ticker=AAPL", nil)
 	w := httptest.NewRecorder()
@@ -260,73 +504,99 @@ func TestHandler_Poll_PendingPrediction(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	
 	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
 	require.NoError(t, err)
 	assert.Equal(t, "pending", response["status"])
+	assert.Equal(t, job.ID, response["job_id"])
 	assert.Nil(t, response["predictions"])
-
-	// Clean up
-	close(predictionCh)
 }
 
 func TestHandler_Poll_CompletedPrediction(t *testing.T) {
 	handler, router := setupTest()
 
-	// Create a prediction channel with a result
-	predictionCh := make(chan PredictionResponse, 1)
-	testData := map[string]interface{}{"prediction": 123.45}
-	predictionCh <- PredictionResponse{
+	// Create a job with a result
+	job, err := handler.jobs.Create("AAPL")
+	require.NoError(t, err)
+	testData := &Prediction{
+		Predictions: []float64{123.45},
+		YTest:       []float64{120},
+		Evaluation:  forecast.Evaluate([]float64{120}, []float64{123.45}),
+	}
+	require.NoError(t, handler.jobs.Complete(job.ID, PredictionResponse{
 		Status: "success",
 		Data:   testData,
 		Error:  nil,
-	}
-	handler.storePredictionChannel("AAPL", predictionCh)
-
-	req, _ := http.NewRequest("GET", "/poll?ticker=AAPL", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
+	}))
 
-	assert.Equal(t, http.StatusOK, w.Code)
-	
-	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	require.NoError(t, err)
-	assert.Equal(t, "success", response["status"])
-	assert.NotNil(t, response["predictions"])
+	// The result stays readable across polls
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/poll?ticker=AAPL", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
 
-	// Verify channel is removed after consumption
-	_, exists := handler.getPredictionChannel("AAPL")
-	assert.False(t, exists)
+		assert.Equal(t, http.StatusOK, w.Code)
 
-	close(predictionCh)
+		var response map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		require.NoError(t, err)
+		assert.Equal(t, "success", response["status"])
+		assert.NotNil(t, response["predictions"])
+		assert.NotNil(t, response["evaluation"])
+	}
 }
 
 func TestHandler_Poll_FailedPrediction(t *testing.T) {
 	handler, router := setupTest()
 
-	// Create a prediction channel with an error result
-	predictionCh := make(chan PredictionResponse, 1)
-	predictionCh <- PredictionResponse{
+	// Create a job with an error result
+	job, err := handler.jobs.Create("AAPL")
+	require.NoError(t, err)
+	require.NoError(t, handler.jobs.Complete(job.ID, PredictionResponse{
 		Status: "failed",
 		Data:   nil,
 		Error:  fmt.Errorf("prediction failed"),
-	}
-	handler.storePredictionChannel("AAPL", predictionCh)
+	}))
 
-	req, _ := http.NewRequest("GET", "/poll?ticker=AAPL", nil)
+	req, _ := http.NewRequest("GET", "/poll?job_id="+job.ID, nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	
 	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
 	require.NoError(t, err)
 	assert.Equal(t, "failed", response["status"])
 	assert.Nil(t, response["predictions"])
 	assert.Equal(t, "prediction failed", response["error"])
+}
+
+func TestHandler_Job(t *testing.T) {
+	handler, router := setupTest()
+
+	first, err := handler.jobs.Create("AAPL")
+	require.NoError(t, err)
+	second, err := handler.jobs.Create("AAPL")
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	req, _ := http.NewRequest("GET", "/jobs/"+first.ID, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	close(predictionCh)
+	var response map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, response["id"])
+	assert.Equal(t, "AAPL", response["ticker"])
+	assert.Equal(t, "queued", response["status"])
+
+	req, _ = http.NewRequest("GET", "/jobs/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 func TestPredictionService_RequestPrediction(t *testing.T) {
@@ -337,16 +607,17 @@ func TestPredictionService_RequestPrediction(t *testing.T) {
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"predictions":{"predictions":[100.1,101.2],"y_test":[99.8,100.5],"dates":["2025-07-20","2025-07-21"]}}`))
+		w.Write([]byte(`{"predictions":[100.1,101.2],"y_test":[99.8,100.5],"dates":["2025-07-20","2025-07-21"]}`))
 	}))
 	defer fakeServer.Close()
 
-	// Set env variable so your handler picks this fake backend
-	t.Setenv("ML_BACKEND", fakeServer.URL)
+	// Point the service at the fake backend
+	cfg := testConfig(1)
+	cfg.ML.URL = fakeServer.URL
 
 	// 🔧 Create the PredictionService
-	ps := NewPredictionService(1)
-	defer close(ps.requestCh)
+	ps := NewPredictionService(cfg, nil)
+	defer ps.queue.close()
 
 	testData := []byte("Close,Other\n100,foo\n101,foo\n102,foo\n")
 	testFileName := "test.csv"
@@ -361,34 +632,74 @@ func TestPredictionService_RequestPrediction(t *testing.T) {
 		assert.Equal(t, "success", response.Status)
 		assert.Nil(t, response.Error)
 
-		data, ok := response.Data.(map[string]interface{})
-		assert.True(t, ok, "data should be a map")
+		require.NotNil(t, response.Data)
+		assert.Equal(t, []float64{100.1, 101.2}, response.Data.Predictions)
+		assert.Equal(t, "lstm", response.Data.Model)
 
-		preds, ok := data["predictions"]
-		assert.True(t, ok, "predictions key should be present")
-		assert.NotNil(t, preds)
+		// The forecast is scored against the actuals
+		require.NotNil(t, response.Data.Evaluation)
+		assert.Equal(t, 2, response.Data.Evaluation.N)
+		assert.InDelta(t, 0.5, float64(response.Data.Evaluation.MAE), 1e-9)
 
 	case <-time.After(5 * time.Second):
 		t.Fatal("Timeout waiting for prediction response")
 	}
 }
 
+func TestPredictionService_Fallback(t *testing.T) {
+	// An ML backend that is down
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer fakeServer.Close()
+
+	for name, backend := range map[string]string{"unset": "", "down": fakeServer.URL} {
+		t.Run(name, func(t *testing.T) {
+			ps := NewPredictionService(testConfig(1), nil)
+			ps.ml = NewMLClient(backend, testMLClientConfig())
+			defer ps.queue.close()
+
+			csvData := "Date,Close"
+			for i := 1; i <= 100; i++ {
+				csvData += fmt.Sprintf("\n2023-%02d-%02d,%d", i/28+1, i%28+1, 100+i)
+			}
+
+			select {
+			case response := <-ps.RequestPrediction([]byte(csvData), "test.csv"):
+				require.Equal(t, "success", response.Status)
+				data := response.Data
+				require.NotNil(t, data)
+				assert.Equal(t, "fallback", data.Source)
+				assert.NotEmpty(t, data.Model)
+				assert.Len(t, data.Predictions, 20)
+				assert.Len(t, data.YTest, 20)
+				assert.Len(t, data.Dates, 20)
+				assert.NotNil(t, data.Evaluation)
+				assert.NotEmpty(t, data.FallbackReason)
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timeout waiting for prediction response")
+			}
+		})
+	}
+}
+
 func TestPredictionService_ChannelFull(t *testing.T) {
 	// Create a service with a small buffer and no workers
 	ps := &PredictionService{
-		requestCh: make(chan PredictionRequest, 1), // Small buffer
-		workers:   0, // No workers to process requests
+		queue:   newScheduler(SchedulerConfig{Capacity: 1}), // Small buffer
+		workers: 0,                                          // No workers to process requests
+		flights: make(map[string]*flight),
 	}
 
-	testData := []byte("test data")
 	testFileName := "test.csv"
 
-	// Fill the channel
-	ps.RequestPrediction(testData, testFileName)
-	
-	// This should return immediately with an error
-	responseCh := ps.RequestPrediction(testData, testFileName)
-	
+	// Fill the queue
+	ps.RequestPrediction([]byte("test data"), testFileName)
+
+	// This should return immediately with an error, an identical upload would
+	// share the queued request instead
+	responseCh := ps.RequestPrediction([]byte("other test data"), testFileName)
+
 	select {
 	case response := <-responseCh:
 		assert.Equal(t, "failed", response.Status)
@@ -398,6 +709,252 @@ func TestPredictionService_ChannelFull(t *testing.T) {
 	}
 }
 
+func TestPredictionService_ShutdownDrainsQueue(t *testing.T) {
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"predictions":[1],"y_test":[1],"dates":["2025-07-20"]}`))
+	}))
+	defer fakeServer.Close()
+
+	ps := NewPredictionService(testConfig(1), nil)
+	ps.ml = NewMLClient(fakeServer.URL, testMLClientConfig())
+
+	first := ps.RequestPrediction([]byte("Close\n1\n"), "test.csv")
+	second := ps.RequestPrediction([]byte("Close\n1\n"), "test.csv")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, ps.Shutdown(ctx))
+
+	// Both queued requests ran to completion before Shutdown returned
+	assert.Equal(t, "success", (<-first).Status)
+	assert.Equal(t, "success", (<-second).Status)
+
+	// Nothing is accepted afterwards
+	assert.False(t, ps.Accepting())
+	response := <-ps.RequestPrediction([]byte("Close\n1\n"), "test.csv")
+	assert.ErrorIs(t, response.Error, ErrShuttingDown)
+}
+
+func TestPredictionService_ShutdownDeadline(t *testing.T) {
+	// An ML backend that never answers on its own
+	release := make(chan struct{})
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer fakeServer.Close()
+	defer close(release)
+
+	jobs := NewMemoryJobStore(time.Minute)
+	ps := NewPredictionService(testConfig(1), jobs)
+	ps.ml = NewMLClient(fakeServer.URL, testMLClientConfig())
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		job, err := jobs.Create("AAPL")
+		require.NoError(t, err)
+		ids = append(ids, job.ID)
+		// Distinct uploads, identical ones would share one ML backend call
+		ps.Submit(PredictionRequest{JobID: job.ID, FileData: []byte(fmt.Sprintf("Close\n%d\n", i+1)), FileName: "test.csv"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := ps.Shutdown(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 prediction job(s) aborted")
+
+	// The in-flight and the queued job are both failed, not left pending
+	for _, id := range ids {
+		job, ok := jobs.Get(id)
+		require.True(t, ok)
+		assert.Equal(t, JobFailed, job.Status)
+		assert.Equal(t, ErrShuttingDown.Error(), job.Error)
+	}
+}
+
+func TestHandler_Metric_ShuttingDown(t *testing.T) {
+	handler, router := setupTest()
+	require.NoError(t, handler.Shutdown(context.Background()))
+
+	body, contentType, err := createMultipartForm("Date,Close\n2023-01-01,100\n2023-01-02,101", "AAPL")
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/metric", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestPredictionService_Cancel(t *testing.T) {
+	// An ML backend that blocks until the caller goes away
+	started := make(chan struct{}, 2)
+	aborted := make(chan struct{}, 2)
+	fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Drain the upload so the server notices the client hanging up
+		io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		<-r.Context().Done()
+		aborted <- struct{}{}
+	}))
+	defer fakeServer.Close()
+
+	jobs := NewMemoryJobStore(time.Minute)
+	ps := NewPredictionService(testConfig(1), jobs)
+	ps.ml = NewMLClient(fakeServer.URL, testMLClientConfig())
+	defer ps.queue.close()
+
+	running, err := jobs.Create("AAPL")
+	require.NoError(t, err)
+	queued, err := jobs.Create("MSFT")
+	require.NoError(t, err)
+	runningCh := ps.Submit(PredictionRequest{JobID: running.ID, FileData: []byte("Close\n1\n"), FileName: "a.csv"})
+	queuedCh := ps.Submit(PredictionRequest{JobID: queued.ID, FileData: []byte("Close\n1\n"), FileName: "b.csv"})
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ML backend was never called")
+	}
+
+	// The queued job is dropped before a worker reaches it
+	require.True(t, ps.Cancel(queued.ID))
+	job, _ := jobs.Get(queued.ID)
+	assert.Equal(t, JobCancelled, job.Status)
+
+	// The running job's upstream request is aborted
+	require.True(t, ps.Cancel(running.ID))
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ML backend request was not aborted")
+	}
+
+	for _, ch := range []<-chan PredictionResponse{runningCh, queuedCh} {
+		select {
+		case response := <-ch:
+			assert.Equal(t, "cancelled", response.Status)
+			assert.ErrorIs(t, response.Error, ErrJobCancelled)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timeout waiting for cancelled response")
+		}
+	}
+	job, _ = jobs.Get(running.ID)
+	assert.Equal(t, JobCancelled, job.Status)
+	assert.Len(t, started, 0, "the queued job must not reach the ML backend")
+
+	// Finished jobs cannot be cancelled again
+	assert.False(t, ps.Cancel(running.ID))
+}
+
+func TestHandler_CancelJob(t *testing.T) {
+	handler, router := setupTest()
+
+	job, err := handler.jobs.Create("AAPL")
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("DELETE", "/jobs/"+job.ID, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "cancelled", response["status"])
+
+	req, _ = http.NewRequest("GET", "/poll?job_id="+job.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "cancelled", response["status"])
+
+	// Cancelling twice conflicts, unknown jobs are not found
+	req, _ = http.NewRequest("DELETE", "/jobs/"+job.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/jobs/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_Metric_QueueFull(t *testing.T) {
+	handler, router := setupTest()
+	// No workers, room for a single queued job
+	cfg := testConfig(0)
+	cfg.Predictions.QueueCapacity = 1
+	handler.predictionService = NewPredictionService(cfg, handler.jobs)
+	handler.predictionService.latency.observe(3 * time.Second)
+
+	post := func(lastClose int) *httptest.ResponseRecorder {
+		csvData := fmt.Sprintf("Date,Close\n2023-01-01,100\n2023-01-02,%d", lastClose)
+		body, contentType, err := createMultipartForm(csvData, "AAPL")
+		require.NoError(t, err)
+		req, _ := http.NewRequest("POST", "/metric", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, post(101).Code)
+
+	// A different upload needs a queue slot of its own
+	w := post(102)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	// The queued job and this one, at the observed 3s each
+	assert.Equal(t, "6", w.Header().Get("Retry-After"))
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Contains(t, response["error"], "busy")
+	assert.Equal(t, float64(6), response["retry_after"])
+}
+
+func TestPredictionService_RetryAfter(t *testing.T) {
+	ps := NewPredictionService(testConfig(2), nil)
+	defer ps.queue.close()
+
+	// Nothing observed yet, nothing queued
+	assert.Equal(t, defaultJobLatency, ps.RetryAfter())
+
+	ps.latency.observe(10 * time.Second)
+	ps.latency.observe(5 * time.Second)
+	assert.Equal(t, 9*time.Second, ps.latency.average())
+}
+
+func TestHandler_Prometheus(t *testing.T) {
+	_, router := setupTest()
+
+	csvData := "Date,Close\n2023-01-01,100\nbad,row\n2023-01-02,101"
+	body, contentType, err := createMultipartForm(csvData, "AAPL")
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", "/metric", body)
+	req.Header.Set("Content-Type", contentType)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	out := w.Body.String()
+	assert.Contains(t, out, `http_requests_total{method="POST",route="/metric",status="200"}`)
+	assert.Contains(t, out, `http_request_duration_seconds_bucket{method="POST",route="/metric",le="+Inf"}`)
+	assert.Contains(t, out, "# TYPE prediction_queue_depth gauge")
+	assert.Contains(t, out, "# TYPE prediction_workers_busy gauge")
+	assert.Contains(t, out, `csv_rows_total{result="skipped"}`)
+	assert.Contains(t, out, "csv_parse_duration_seconds_count")
+}
+
 // Integration test that combines metric calculation and polling
 func TestHandler_MetricAndPoll_Integration(t *testing.T) {
 	// Skip this test if ML_BACKEND is not set (to avoid FastAPI calls in unit tests)
@@ -464,4 +1021,46 @@ func BenchmarkHandler_Metric(b *testing.B) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 	}
-}
\ No newline at end of file
+}
+
+func TestHandler_Backtest(t *testing.T) {
+	_, router := setupTest()
+
+	csvData := `Date,Open,High,Low,Close,Volume`
+	for i := 1; i <= 250; i++ {
+		price := 100.0 + 10*math.Sin(float64(i)/10)
+		csvData += fmt.Sprintf("\n2023-%02d-%02d,%.2f,%.2f,%.2f,%.2f,%d",
+			i/28+1, i%28+1, price, price+1, price-1, price, 1000000)
+	}
+
+	body, contentType, err := createMultipartFormWithFields(csvData, map[string]string{
+		"strategy": "rsi",
+		"period":   "7",
+		"slippage": "0.001",
+	})
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest("POST", "/backtest", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	require.NoError(t, err)
+	assert.Equal(t, "rsi(7)", response["strategy"])
+	assert.Len(t, response["equity"], 250)
+	assert.NotEmpty(t, response["trades"])
+	assert.Contains(t, response["stats"], "max_drawdown")
+
+	// Unknown strategies are rejected
+	body, contentType, err = createMultipartFormWithFields(csvData, map[string]string{"strategy": "magic"})
+	require.NoError(t, err)
+	req, _ = http.NewRequest("POST", "/backtest", body)
+	req.Header.Set("Content-Type", contentType)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxIndicators bounds how many indicators one /metric request may select
+const maxIndicators = 32
+
+// parseIndicatorSpecs parses the indicators form field against the
+// registered indicators, see pkg.Registry.ParseSpecs
+func parseIndicatorSpecs(raw string) ([]pkg.Spec, error) {
+	specs, err := pkg.Indicators.ParseSpecs(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid indicators: %s", strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+	if len(specs) > maxIndicators {
+		return nil, fmt.Errorf("invalid indicators: at most %d may be selected", maxIndicators)
+	}
+	return specs, nil
+}
+
+// computeIndicators extracts the columns the specs need once and computes
+// every spec concurrently, results are in spec order
+func computeIndicators(series *pkg.Series, specs []pkg.Spec) []any {
+	var inputs []pkg.Input
+	for _, spec := range specs {
+		inputs = append(inputs, spec.Indicator.Inputs()...)
+	}
+	in := pkg.InputsFrom(series, inputs...)
+
+	results := make([]any, len(specs))
+	g := &errgroup.Group{}
+	for i, spec := range specs {
+		g.Go(func() error {
+			results[i] = spec.Compute(in)
+			return nil
+		})
+	}
+	g.Wait()
+	return results
+}
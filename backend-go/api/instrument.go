@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "prediction_queue_depth",
+		Help: "Prediction requests waiting for a worker.",
+	})
+	workersBusy = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "prediction_workers_busy",
+		Help: "Workers currently running a prediction.",
+	})
+	jobOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "prediction_jobs_total",
+		Help: "Finished prediction requests by outcome.",
+	}, []string{"outcome"})
+
+	mlDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ml_backend_request_duration_seconds",
+		Help:    "ML backend call latency per attempt.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"outcome"})
+	mlErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ml_backend_errors_total",
+		Help: "Failed ML backend calls by reason, an HTTP status code or a transport failure.",
+	}, []string{"reason"})
+
+	csvParseDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "csv_parse_duration_seconds",
+		Help:    "Time spent reading and parsing uploaded CSV files.",
+		Buckets: prometheus.DefBuckets,
+	})
+	metricCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "metric_cache_requests_total",
+		Help: "Lookups of the /metric result cache by result (hit, miss).",
+	}, []string{"result"})
+	predictionsDeduplicated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "prediction_requests_deduplicated_total",
+		Help: "Prediction requests that shared the ML backend call of an identical in-flight request.",
+	})
+	batchTickers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "metric_batch_tickers_total",
+		Help: "Tickers of /metric/batch requests by result (ok, error).",
+	}, []string{"result"})
+	csvRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "csv_rows_total",
+		Help: "Rows read from uploaded CSV files, parsed or skipped.",
+	}, []string{"result"})
+
+	// promHandler serves the default registry, which promauto registers with
+	promHandler = promhttp.Handler()
+)
+
+// Instrument records request counts and latency per matched route
+func Instrument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(route, c.Request.Method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Prometheus serves the process metrics in the Prometheus text format
+func (h *Handler) Prometheus(c *gin.Context) {
+	promHandler.ServeHTTP(c.Writer, c.Request)
+}
+
+// observeMLAttempt records one call to the ML backend
+func observeMLAttempt(d time.Duration, err error) {
+	if err == nil {
+		mlDuration.WithLabelValues("success").Observe(d.Seconds())
+		return
+	}
+	mlDuration.WithLabelValues("error").Observe(d.Seconds())
+	mlErrors.WithLabelValues(mlErrorReason(err)).Inc()
+}
+
+func mlErrorReason(err error) string {
+	var mlErr *MLError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "cancelled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &mlErr) && mlErr.StatusCode != 0:
+		return strconv.Itoa(mlErr.StatusCode)
+	case errors.As(err, &mlErr):
+		return "transport"
+	}
+	return "invalid_response"
+}
+
+// jobOutcome labels a finished request for prediction_jobs_total
+func jobOutcome(resp PredictionResponse) string {
+	switch {
+	case resp.Error == nil:
+		return "succeeded"
+	case errors.Is(resp.Error, ErrJobCancelled):
+		return "cancelled"
+	case errors.Is(resp.Error, ErrQueueFull), errors.Is(resp.Error, ErrClientQueueFull):
+		return "rejected"
+	case errors.Is(resp.Error, ErrShuttingDown):
+		return "aborted"
+	}
+	return "failed"
+}
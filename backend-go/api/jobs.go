@@ -0,0 +1,270 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// JobStatus is the lifecycle state of a prediction job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// ErrJobCancelled is the outcome of a job cancelled by its caller
+var ErrJobCancelled = errors.New("job cancelled")
+
+// Done reports whether the job has reached a terminal state
+func (s JobStatus) Done() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Job is a single prediction request tracked by a JobStore
+type Job struct {
+	ID         string      `json:"id"`
+	Ticker     string      `json:"ticker"`
+	Status     JobStatus   `json:"status"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	StartedAt  *time.Time  `json:"started_at,omitempty"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+	Result     *Prediction `json:"result,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	// UpstreamStatus is the ML backend's HTTP status when it caused the failure
+	UpstreamStatus int `json:"upstream_status,omitempty"`
+}
+
+// JobStore keeps prediction jobs so they can be re-read until they expire
+type JobStore interface {
+	Create(ticker string) (Job, error)
+	Get(id string) (Job, bool)
+	Latest(ticker string) (Job, bool)
+	MarkRunning(id string) error
+	// Complete records the outcome, the first terminal state wins so a worker
+	// finishing after a cancellation does not overwrite it
+	Complete(id string, resp PredictionResponse) error
+	// Subscribe returns the current snapshot and a channel of later updates,
+	// the channel is closed once the job reaches a terminal state
+	Subscribe(id string) (Job, <-chan Job, func(), bool)
+}
+
+// MemoryJobStore is an in-process JobStore with TTL based expiry
+type MemoryJobStore struct {
+	mu       sync.RWMutex
+	jobs     map[string]*Job
+	byTicker map[string][]string
+	subs     map[string][]chan Job
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+func NewMemoryJobStore(ttl time.Duration) *MemoryJobStore {
+	return &MemoryJobStore{
+		jobs:     make(map[string]*Job),
+		byTicker: make(map[string][]string),
+		subs:     make(map[string][]chan Job),
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *MemoryJobStore) Create(ticker string) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.pruneLocked(now)
+
+	job := &Job{
+		ID:        id,
+		Ticker:    ticker,
+		Status:    JobQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	s.jobs[id] = job
+	s.byTicker[ticker] = append(s.byTicker[ticker], id)
+
+	return *job, nil
+}
+
+func (s *MemoryJobStore) Get(id string) (Job, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	job, ok := s.jobs[id]
+	if !ok || s.expired(job, s.now()) {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Latest returns the most recently created live job for a ticker
+func (s *MemoryJobStore) Latest(ticker string) (Job, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	ids := s.byTicker[ticker]
+	for i := len(ids) - 1; i >= 0; i-- {
+		if job, ok := s.jobs[ids[i]]; ok && !s.expired(job, now) {
+			return *job, true
+		}
+	}
+	return Job{}, false
+}
+
+func (s *MemoryJobStore) MarkRunning(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+	if job.Status.Done() {
+		return nil
+	}
+
+	now := s.now()
+	job.Status = JobRunning
+	job.StartedAt = &now
+	job.UpdatedAt = now
+	s.publishLocked(job)
+	return nil
+}
+
+func (s *MemoryJobStore) Complete(id string, resp PredictionResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+	if job.Status.Done() {
+		return nil
+	}
+
+	now := s.now()
+	if errors.Is(resp.Error, ErrJobCancelled) {
+		job.Status = JobCancelled
+		job.Error = resp.Error.Error()
+	} else if resp.Error != nil {
+		job.Status = JobFailed
+		job.Error = resp.Error.Error()
+		var mlErr *MLError
+		if errors.As(resp.Error, &mlErr) {
+			job.UpstreamStatus = mlErr.StatusCode
+		}
+	} else {
+		job.Status = JobSucceeded
+		job.Result = resp.Data
+	}
+	job.FinishedAt = &now
+	job.UpdatedAt = now
+	// The TTL starts counting again from completion so results stay readable
+	job.ExpiresAt = now.Add(s.ttl)
+	s.publishLocked(job)
+	return nil
+}
+
+func (s *MemoryJobStore) Subscribe(id string) (Job, <-chan Job, func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || s.expired(job, s.now()) {
+		return Job{}, nil, func() {}, false
+	}
+
+	ch := make(chan Job, 1)
+	if job.Status.Done() {
+		close(ch)
+		return *job, ch, func() {}, true
+	}
+	s.subs[id] = append(s.subs[id], ch)
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		subs := s.subs[id]
+		for i, sub := range subs {
+			if sub == ch {
+				s.subs[id] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+		if len(s.subs[id]) == 0 {
+			delete(s.subs, id)
+		}
+	}
+	return *job, ch, unsubscribe, true
+}
+
+// publishLocked hands the latest snapshot to every subscriber. Each channel
+// holds one pending update, a slow reader only ever sees the newest state.
+func (s *MemoryJobStore) publishLocked(job *Job) {
+	for _, ch := range s.subs[job.ID] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- *job
+		if job.Status.Done() {
+			close(ch)
+		}
+	}
+	if job.Status.Done() {
+		delete(s.subs, job.ID)
+	}
+}
+
+func (s *MemoryJobStore) expired(job *Job, now time.Time) bool {
+	return s.ttl > 0 && now.After(job.ExpiresAt)
+}
+
+// pruneLocked drops expired jobs, caller must hold the write lock
+func (s *MemoryJobStore) pruneLocked(now time.Time) {
+	for ticker, ids := range s.byTicker {
+		live := ids[:0]
+		for _, id := range ids {
+			job := s.jobs[id]
+			if s.expired(job, now) {
+				delete(s.jobs, id)
+				continue
+			}
+			live = append(live, id)
+		}
+		if len(live) == 0 {
+			delete(s.byTicker, ticker)
+		} else {
+			s.byTicker[ticker] = live
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryJobStore_Lifecycle(t *testing.T) {
+	store := NewMemoryJobStore(time.Minute)
+
+	job, err := store.Create("AAPL")
+	require.NoError(t, err)
+	assert.Equal(t, JobQueued, job.Status)
+
+	require.NoError(t, store.MarkRunning(job.ID))
+	got, ok := store.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, JobRunning, got.Status)
+	assert.NotNil(t, got.StartedAt)
+
+	require.NoError(t, store.Complete(job.ID, PredictionResponse{Status: "success", Data: &Prediction{Model: "lstm"}}))
+	got, ok = store.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, JobSucceeded, got.Status)
+	assert.Equal(t, "lstm", got.Result.Model)
+	assert.NotNil(t, got.FinishedAt)
+
+	assert.Error(t, store.MarkRunning("missing"))
+}
+
+func TestMemoryJobStore_ConcurrentJobsPerTicker(t *testing.T) {
+	store := NewMemoryJobStore(time.Minute)
+
+	first, err := store.Create("AAPL")
+	require.NoError(t, err)
+	second, err := store.Create("AAPL")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Complete(first.ID, PredictionResponse{Error: fmt.Errorf("boom")}))
+
+	// Completing one job must not disturb the other
+	got, ok := store.Get(second.ID)
+	require.True(t, ok)
+	assert.Equal(t, JobQueued, got.Status)
+
+	latest, ok := store.Latest("AAPL")
+	require.True(t, ok)
+	assert.Equal(t, second.ID, latest.ID)
+}
+
+func TestMemoryJobStore_Expiry(t *testing.T) {
+	store := NewMemoryJobStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	job, err := store.Create("AAPL")
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	_, ok := store.Get(job.ID)
+	assert.False(t, ok)
+	_, ok = store.Latest("AAPL")
+	assert.False(t, ok)
+
+	// Expired jobs are pruned on the next create
+	_, err = store.Create("MSFT")
+	require.NoError(t, err)
+	assert.NotContains(t, store.jobs, job.ID)
+}
+
+func TestMemoryJobStore_Subscribe(t *testing.T) {
+	store := NewMemoryJobStore(time.Minute)
+
+	job, err := store.Create("AAPL")
+	require.NoError(t, err)
+
+	snapshot, updates, unsubscribe, ok := store.Subscribe(job.ID)
+	require.True(t, ok)
+	defer unsubscribe()
+	assert.Equal(t, JobQueued, snapshot.Status)
+
+	require.NoError(t, store.MarkRunning(job.ID))
+	assert.Equal(t, JobRunning, (<-updates).Status)
+
+	require.NoError(t, store.Complete(job.ID, PredictionResponse{Status: "success"}))
+	assert.Equal(t, JobSucceeded, (<-updates).Status)
+
+	_, open := <-updates
+	assert.False(t, open, "channel should close after a terminal update")
+
+	_, _, _, ok = store.Subscribe("missing")
+	assert.False(t, ok)
+}
+
+func TestMemoryJobStore_UpstreamStatus(t *testing.T) {
+	store := NewMemoryJobStore(time.Minute)
+
+	job, err := store.Create("AAPL")
+	require.NoError(t, err)
+	require.NoError(t, store.Complete(job.ID, PredictionResponse{
+		Status: "failed",
+		Error:  &MLError{StatusCode: 502, Body: "bad gateway", Attempts: 3},
+	}))
+
+	got, ok := store.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, JobFailed, got.Status)
+	assert.Equal(t, 502, got.UpstreamStatus)
+	assert.Contains(t, got.Error, "bad gateway")
+}
@@ -0,0 +1,261 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/config"
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+	"github.com/Samudra-G/stockprediction-refactored/store"
+	"github.com/gin-gonic/gin"
+)
+
+// liveSeries is the incremental indicator state of one ticker. It only holds
+// running values, not the price history, so appending a bar is O(1).
+type liveSeries struct {
+	Bars     int           `json:"bars"`
+	LastDate time.Time     `json:"last_date"`
+	RSI      pkg.RSIState  `json:"rsi"`
+	EMA      pkg.EMAState  `json:"ema"`
+	MACD     pkg.MACDState `json:"macd"`
+}
+
+// liveValue is the indicator output at one appended bar
+type liveValue struct {
+	Date      string     `json:"date,omitempty"`
+	Close     float64    `json:"close"`
+	RSI       pkg.Number `json:"rsi"`
+	EMA       pkg.Number `json:"ema"`
+	MACD      pkg.Number `json:"macd"`
+	Signal    pkg.Number `json:"signal"`
+	Histogram pkg.Number `json:"histogram"`
+}
+
+func (s *liveSeries) update(bar pkg.Bar) liveValue {
+	s.Bars++
+	if !bar.Time.IsZero() {
+		s.LastDate = bar.Time
+	}
+	macd := s.MACD.Update(bar.Close)
+	v := liveValue{
+		Close:     bar.Close,
+		RSI:       pkg.Number(s.RSI.Update(bar.Close)),
+		EMA:       pkg.Number(s.EMA.Update(bar.Close)),
+		MACD:      pkg.Number(macd.MACD),
+		Signal:    pkg.Number(macd.Signal),
+		Histogram: pkg.Number(macd.Histogram),
+	}
+	if !bar.Time.IsZero() {
+		v.Date = bar.Time.Format("2006-01-02")
+	}
+	return v
+}
+
+// liveStore keeps a liveSeries per ticker, seeded by /metric uploads. Tickers
+// are normalized by the caller, see store.NormalizeTicker.
+type liveStore struct {
+	mu         sync.Mutex
+	series     map[string]*liveSeries
+	indicators config.Indicators
+	// load returns the stored history of a ticker not seen since startup,
+	// nil when there is no store
+	load func(ticker string) (*pkg.Series, error)
+}
+
+func newLiveStore(indicators config.Indicators) *liveStore {
+	return &liveStore{series: make(map[string]*liveSeries), indicators: indicators}
+}
+
+func (l *liveStore) newSeries() *liveSeries {
+	return &liveSeries{
+		RSI:  *pkg.NewRSIState(l.indicators.RSIPeriod),
+		EMA:  *pkg.NewEMAState(l.indicators.EMAPeriod),
+		MACD: *pkg.NewMACDState(12, 26, 9),
+	}
+}
+
+// reset replaces the ticker's state with one replayed from a full history
+func (l *liveStore) reset(ticker string, series *pkg.Series) {
+	s := l.newSeries()
+	for _, bar := range series.Bars {
+		s.update(bar)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.series[ticker] = s
+}
+
+// errBarOrder is returned when appended bars do not move forward in time
+var errBarOrder = errors.New("bars must be dated after the last bar of the series")
+
+// appendBars applies the bars to the ticker's state, starting a new series
+// for an unknown ticker. persist, when not nil, saves the bars once they are
+// known to be in order. Nothing is applied when any bar is out of order or
+// persist fails.
+func (l *liveStore) appendBars(ticker string, bars []pkg.Bar, persist func([]pkg.Bar) error) (*liveSeries, []liveValue, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, ok := l.series[ticker]
+	if !ok {
+		s = l.newSeries()
+		if l.load != nil {
+			if stored, err := l.load(ticker); err == nil {
+				for _, bar := range stored.Bars {
+					s.update(bar)
+				}
+			}
+		}
+	}
+	last := s.LastDate
+	for _, bar := range bars {
+		if bar.Time.IsZero() {
+			continue
+		}
+		if !last.IsZero() && !bar.Time.After(last) {
+			return nil, nil, fmt.Errorf("%w: %s is not after %s", errBarOrder,
+				bar.Time.Format("2006-01-02"), last.Format("2006-01-02"))
+		}
+		last = bar.Time
+	}
+	if persist != nil {
+		if err := persist(bars); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	values := make([]liveValue, len(bars))
+	for i, bar := range bars {
+		values[i] = s.update(bar)
+	}
+	l.series[ticker] = s
+	snapshot := *s
+	return &snapshot, values, nil
+}
+
+// barInput is one bar of a POST /series/{ticker}/bars body. Only the close
+// is required, missing prices fall back to it and a missing volume is zero,
+// as for a CSV upload. The date is optional unless the series store is
+// enabled, and must increase when given.
+type barInput struct {
+	Date     string   `json:"date"`
+	Open     *float64 `json:"open"`
+	High     *float64 `json:"high"`
+	Low      *float64 `json:"low"`
+	Close    *float64 `json:"close"`
+	AdjClose *float64 `json:"adj_close"`
+	Volume   *float64 `json:"volume"`
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// bar converts the input, filling in the fields that were left out
+func (in barInput) bar() (pkg.Bar, error) {
+	if in.Close == nil || !finite(*in.Close) {
+		return pkg.Bar{}, errors.New("close is required")
+	}
+	bar := pkg.Bar{Close: *in.Close}
+	for _, f := range []struct {
+		name     string
+		in       *float64
+		out      *float64
+		fallback float64
+	}{
+		{"open", in.Open, &bar.Open, bar.Close},
+		{"high", in.High, &bar.High, bar.Close},
+		{"low", in.Low, &bar.Low, bar.Close},
+		{"adj_close", in.AdjClose, &bar.AdjClose, bar.Close},
+		{"volume", in.Volume, &bar.Volume, 0},
+	} {
+		*f.out = f.fallback
+		if f.in != nil {
+			if !finite(*f.in) {
+				return pkg.Bar{}, fmt.Errorf("%s must be a finite number", f.name)
+			}
+			*f.out = *f.in
+		}
+	}
+	if in.Date != "" {
+		t, err := time.Parse("2006-01-02", in.Date)
+		if err != nil {
+			return pkg.Bar{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", in.Date)
+		}
+		bar.Time = t
+	}
+	return bar, nil
+}
+
+// AppendBars handles POST /series/:ticker/bars with a body such as
+// {"bars":[{"date":"2024-01-02","close":101.5}]} and returns the indicator
+// values at every appended bar
+func (h *Handler) AppendBars(c *gin.Context) {
+	ticker, err := store.NormalizeTicker(c.Param("ticker"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var body struct {
+		Bars []barInput `json:"bars"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body: " + err.Error()})
+		return
+	}
+	if len(body.Bars) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one bar is required"})
+		return
+	}
+
+	bars := make([]pkg.Bar, len(body.Bars))
+	for i, in := range body.Bars {
+		bar, err := in.bar()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("bar %d: %v", i, err)})
+			return
+		}
+		// The store keys bars by date, an undated bar would only reach the
+		// live state and be lost on the next reset
+		if h.series != nil && bar.Time.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("bar %d: date is required", i)})
+			return
+		}
+		bars[i] = bar
+	}
+
+	// The bars are stored before the live state moves, so the two never
+	// disagree
+	var persist func([]pkg.Bar) error
+	if h.series != nil {
+		persist = func(bars []pkg.Bar) error {
+			_, err := h.series.Upsert(ticker, bars)
+			return err
+		}
+	}
+	series, values, err := h.live.appendBars(ticker, bars, persist)
+	if errors.Is(err, errBarOrder) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		loggerFrom(c.Request.Context()).Error("Failed to append bars", "ticker", ticker, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := gin.H{
+		"ticker": ticker,
+		"bars":   series.Bars,
+		"values": values,
+	}
+	if !series.LastDate.IsZero() {
+		resp["last_date"] = series.LastDate.Format("2006-01-02")
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postBars(t *testing.T, router http.Handler, ticker, body string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest("POST", "/series/"+ticker+"/bars", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func TestHandler_AppendBars(t *testing.T) {
+	_, router := setupTest()
+
+	// Seed the ticker with an upload
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	closes := make([]float64, 42)
+	csvData := "Date,Close"
+	for i := range closes {
+		closes[i] = 100 + 5*math.Sin(float64(i)/3)
+		if i < 40 {
+			csvData += fmt.Sprintf("\n%s,%v", start.AddDate(0, 0, i).Format("2006-01-02"), closes[i])
+		}
+	}
+	body, contentType, err := createMultipartForm(csvData, "aapl")
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", "/metric", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	code, resp := postBars(t, router, "AAPL", fmt.Sprintf(
+		`{"bars":[{"date":"2023-02-10","close":%v},{"date":"2023-02-11","close":%v}]}`, closes[40], closes[41]))
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, float64(42), resp["bars"])
+	assert.Equal(t, "2023-02-11", resp["last_date"])
+
+	// The incremental values are those of the batch functions over the whole history
+	rsi := pkg.RSI(closes, 14)
+	macdLine, signal, _ := pkg.MACD(closes)
+	values := resp["values"].([]interface{})
+	require.Len(t, values, 2)
+	last := values[1].(map[string]interface{})
+	assert.Equal(t, "2023-02-11", last["date"])
+	assert.Equal(t, rsi[len(rsi)-1], last["rsi"])
+	assert.Equal(t, pkg.EMA(closes, 20)[41], last["ema"])
+	assert.Equal(t, macdLine[41], last["macd"])
+	assert.Equal(t, signal[41], last["signal"])
+
+	// Bars must move forward in time
+	code, _ = postBars(t, router, "AAPL", `{"bars":[{"date":"2023-02-11","close":1}]}`)
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, _ = postBars(t, router, "AAPL", `{"bars":[{"date":"2023-02-12"}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Tickers follow the store's rules
+	code, _ = postBars(t, router, "BAD$", `{"bars":[{"close":10}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = postBars(t, router, strings.Repeat("A", 40), `{"bars":[{"close":10}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// An unknown ticker starts warming up from its first bar
+	code, resp = postBars(t, router, "MSFT", `{"bars":[{"close":10}]}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(1), resp["bars"])
+	assert.Nil(t, resp["values"].([]interface{})[0].(map[string]interface{})["rsi"])
+}
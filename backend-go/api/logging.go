@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in and out of the service
+const RequestIDHeader = "X-Request-ID"
+
+type loggerKey struct{}
+
+// NewLogger writes JSON log lines at or above level
+func NewLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// withLogger returns a context whose logs go through logger
+func withLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// loggerFrom returns the logger stored on ctx, or the default logger
+func loggerFrom(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// RequestID tags every request with an ID, reusing the caller's X-Request-ID
+// when present, puts a logger carrying it on the request context and writes
+// one access log line per request
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(RequestIDHeader))
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+
+		logger := slog.Default().With("request_id", id)
+		c.Request = c.Request.WithContext(withLogger(c.Request.Context(), logger))
+
+		start := time.Now()
+		c.Next()
+
+		logger.Info("request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// requestID returns the ID assigned by the RequestID middleware
+func requestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
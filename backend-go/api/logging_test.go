@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureLogs routes the default logger into a buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	prev := slog.Default()
+	slog.SetDefault(NewLogger(buf, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return buf
+}
+
+// logLines decodes JSON log lines
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if raw == "" {
+			continue
+		}
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(raw), &line), raw)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRequestID(t *testing.T) {
+	logs := captureLogs(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/ping", func(c *gin.Context) {
+		loggerFrom(c.Request.Context()).Info("inside handler")
+		c.String(http.StatusOK, requestID(c))
+	})
+
+	// A caller supplied ID is kept
+	req, _ := http.NewRequest("GET", "/ping", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Header().Get(RequestIDHeader))
+	assert.Equal(t, "abc-123", w.Body.String())
+
+	lines := logLines(t, logs)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "inside handler", lines[0]["msg"])
+	assert.Equal(t, "abc-123", lines[0]["request_id"])
+	assert.Equal(t, "request", lines[1]["msg"])
+	assert.Equal(t, "/ping", lines[1]["route"])
+	assert.Equal(t, float64(200), lines[1]["status"])
+
+	// Otherwise one is generated
+	req, _ = http.NewRequest("GET", "/ping", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Len(t, w.Header().Get(RequestIDHeader), 16)
+}
+
+func TestPredictionService_LogsCarryRequestID(t *testing.T) {
+	logs := captureLogs(t)
+
+	ps := NewPredictionService(testConfig(1), nil)
+	ps.ml = NewMLClient("", testMLClientConfig())
+	defer ps.queue.close()
+
+	select {
+	case <-ps.Submit(PredictionRequest{
+		JobID:     "job-1",
+		RequestID: "req-1",
+		FileData:  []byte("Close\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"),
+		FileName:  "test.csv",
+	}):
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for prediction response")
+	}
+
+	lines := logLines(t, logs)
+	require.NotEmpty(t, lines)
+	for _, line := range lines {
+		assert.Equal(t, "job-1", line["job_id"], line["msg"])
+		assert.Equal(t, "req-1", line["request_id"], line["msg"])
+	}
+}
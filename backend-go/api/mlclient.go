@@ -0,0 +1,302 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime/multipart"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+var (
+	// ErrMLBackendUnset is returned when no ML backend URL is configured
+	ErrMLBackendUnset = errors.New("ML_BACKEND is not set")
+	// ErrCircuitOpen is returned without calling the ML backend while it is
+	// considered unhealthy
+	ErrCircuitOpen = errors.New("ml backend circuit breaker is open")
+)
+
+// MLError is a failed call to the ML backend. StatusCode is zero when no
+// response was received (e.g. connection refused or timeout).
+type MLError struct {
+	StatusCode int
+	Body       string
+	Attempts   int
+	Err        error
+}
+
+func (e *MLError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("ml backend returned %d %s after %d attempt(s): %s",
+			e.StatusCode, http.StatusText(e.StatusCode), e.Attempts, e.Body)
+	}
+	return fmt.Sprintf("ml backend request failed after %d attempt(s): %v", e.Attempts, e.Err)
+}
+
+func (e *MLError) Unwrap() error {
+	return e.Err
+}
+
+// transient reports whether retrying the call may succeed
+func (e *MLError) transient() bool {
+	if e.StatusCode == 0 {
+		return true
+	}
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// MLClientConfig tunes timeouts, retries and the circuit breaker, see
+// config.MLBackend for the defaults
+type MLClientConfig struct {
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+	// MaxRetries is the number of attempts after the first one
+	MaxRetries  int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// BreakerThreshold consecutive transient failures open the circuit for
+	// BreakerCooldown, after which a single trial call is let through
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// MLClient calls the FastAPI prediction service
+type MLClient struct {
+	baseURL string
+	cfg     MLClientConfig
+	http    *http.Client
+	breaker *circuitBreaker
+}
+
+func NewMLClient(baseURL string, cfg MLClientConfig) *MLClient {
+	return &MLClient{
+		baseURL: baseURL,
+		cfg:     cfg,
+		// Timeouts come from the per-attempt context
+		http:    &http.Client{},
+		breaker: newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// Predict uploads the CSV to /api/v1/predict, retrying transient failures
+// with jittered exponential backoff
+func (c *MLClient) Predict(ctx context.Context, fileData []byte, fileName string) (*Prediction, error) {
+	if c.baseURL == "" {
+		return nil, ErrMLBackendUnset
+	}
+
+	var lastErr *MLError
+	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := c.backoff(attempt)
+			loggerFrom(ctx).Warn("Retrying ML backend request", "attempt", attempt+1, "backoff_ms", wait.Milliseconds(), "error", lastErr)
+			if err := sleepCtx(ctx, wait); err != nil {
+				lastErr.Err = errors.Join(lastErr.Err, err)
+				return nil, lastErr
+			}
+		}
+		if !c.breaker.allow() {
+			if lastErr != nil {
+				// The breaker tripped during our retries, report the real cause
+				return nil, lastErr
+			}
+			mlErrors.WithLabelValues("circuit_open").Inc()
+			loggerFrom(ctx).Warn("ML backend circuit breaker is open, skipping call")
+			return nil, ErrCircuitOpen
+		}
+
+		start := time.Now()
+		pred, err := c.predictOnce(ctx, fileData, fileName)
+		observeMLAttempt(time.Since(start), err)
+		if err == nil {
+			c.breaker.success()
+			return pred, nil
+		}
+
+		var mlErr *MLError
+		if !errors.As(err, &mlErr) {
+			// Not an upstream failure (e.g. undecodable body), do not retry
+			c.breaker.success()
+			return nil, err
+		}
+		mlErr.Attempts = attempt + 1
+		lastErr = mlErr
+		if errors.Is(mlErr.Err, context.Canceled) {
+			// The caller gave up, this says nothing about the backend's health
+			c.breaker.release()
+			return nil, mlErr
+		}
+		if !mlErr.transient() {
+			c.breaker.success()
+			return nil, mlErr
+		}
+		c.breaker.failure()
+		if ctx.Err() != nil {
+			return nil, mlErr
+		}
+	}
+	return nil, lastErr
+}
+
+// Health calls the ML backend's /health endpoint once, without retries
+func (c *MLClient) Health(ctx context.Context) error {
+	if c.baseURL == "" {
+		return ErrMLBackendUnset
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/health", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health request: %w", err)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return &MLError{Attempts: 1, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &MLError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bytes.TrimSpace(body)),
+			Attempts:   1,
+			Err:        fmt.Errorf("unexpected status %s", resp.Status),
+		}
+	}
+	return nil
+}
+
+func (c *MLClient) predictOnce(ctx context.Context, fileData []byte, fileName string) (*Prediction, error) {
+	bodyBuf := &bytes.Buffer{}
+	writer := multipart.NewWriter(bodyBuf)
+
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(fileData); err != nil {
+		return nil, fmt.Errorf("failed to write file contents: %w", err)
+	}
+	writer.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/v1/predict", bodyBuf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create FastAPI request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, &MLError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &MLError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bytes.TrimSpace(body)),
+			Err:        fmt.Errorf("unexpected status %s", resp.Status),
+		}
+	}
+
+	var result Prediction
+	if err := pkg.FromJSON(resp.Body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode FastAPI response: %w", err)
+	}
+	return &result, nil
+}
+
+// backoff is full-jitter exponential backoff for the given retry number
+func (c *MLClient) backoff(attempt int) time.Duration {
+	ceiling := c.cfg.BackoffBase << (attempt - 1)
+	if ceiling <= 0 || ceiling > c.cfg.BackoffMax {
+		ceiling = c.cfg.BackoffMax
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// circuitBreaker opens after threshold consecutive failures. Once the
+// cooldown passes it lets one trial call through (half-open), whose outcome
+// closes or re-opens the circuit.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	if b.now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// release ends a call without an outcome, e.g. one the caller cancelled, so
+// a half-open circuit lets the next trial through
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// open reports whether calls are currently being short-circuited
+func (b *circuitBreaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.threshold > 0 && b.failures >= b.threshold && b.now().Before(b.openUntil)
+}
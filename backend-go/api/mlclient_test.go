@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMLClientConfig() MLClientConfig {
+	return MLClientConfig{
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		BackoffBase:      time.Millisecond,
+		BackoffMax:       5 * time.Millisecond,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Hour,
+	}
+}
+
+func TestMLClient_RetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"predictions":[1],"y_test":[1],"dates":["2025-07-20"]}`))
+	}))
+	defer server.Close()
+
+	client := NewMLClient(server.URL, testMLClientConfig())
+	pred, err := client.Predict(context.Background(), []byte("Close\n1\n"), "test.csv")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1}, pred.Predictions)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestMLClient_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"detail":"Not enough data for prediction"}`))
+	}))
+	defer server.Close()
+
+	client := NewMLClient(server.URL, testMLClientConfig())
+	_, err := client.Predict(context.Background(), []byte("Close\n1\n"), "test.csv")
+
+	var mlErr *MLError
+	require.True(t, errors.As(err, &mlErr))
+	assert.Equal(t, http.StatusBadRequest, mlErr.StatusCode)
+	assert.Contains(t, mlErr.Body, "Not enough data")
+	assert.Equal(t, 1, mlErr.Attempts)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestMLClient_CircuitBreaker(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewMLClient(server.URL, testMLClientConfig())
+
+	// Three attempts exhaust the retries and trip the breaker
+	_, err := client.Predict(context.Background(), nil, "test.csv")
+	var mlErr *MLError
+	require.True(t, errors.As(err, &mlErr))
+	assert.Equal(t, 3, mlErr.Attempts)
+	assert.True(t, client.breaker.open())
+
+	_, err = client.Predict(context.Background(), nil, "test.csv")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// After the cooldown a single trial goes through
+	client.breaker.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	_, err = client.Predict(context.Background(), nil, "test.csv")
+	assert.True(t, errors.As(err, &mlErr))
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestMLClient_CircuitBreaker_CancelledTrial(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		healthy atomic.Bool
+	)
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		calls.Add(1)
+		if healthy.Load() {
+			w.Write([]byte(`{"predictions":[1],"y_test":[1],"dates":["2025-07-20"]}`))
+			return
+		}
+		if calls.Load() <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		// The half-open trial hangs until its caller gives up
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewMLClient(server.URL, testMLClientConfig())
+	_, err := client.Predict(context.Background(), nil, "test.csv")
+	require.Error(t, err)
+	require.True(t, client.breaker.open())
+	client.breaker.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err = client.Predict(ctx, nil, "test.csv")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(4), calls.Load())
+
+	// The cancelled trial neither closed nor re-opened the circuit, the next
+	// call is the new trial
+	healthy.Store(true)
+	pred, err := client.Predict(context.Background(), nil, "test.csv")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1}, pred.Predictions)
+	assert.Equal(t, int32(5), calls.Load())
+	assert.False(t, client.breaker.open())
+}
+
+func TestMLClient_Unset(t *testing.T) {
+	_, err := NewMLClient("", testMLClientConfig()).Predict(context.Background(), nil, "test.csv")
+	assert.ErrorIs(t, err, ErrMLBackendUnset)
+}
@@ -0,0 +1,460 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/config"
+	"github.com/Samudra-G/stockprediction-refactored/forecast"
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+// ErrShuttingDown is reported for jobs the service will not run because it
+// is shutting down
+var ErrShuttingDown = errors.New("prediction service is shutting down")
+
+// PredictionRequest represents a prediction request
+type PredictionRequest struct {
+	JobID    string
+	FileData []byte
+	FileName string
+	// Series is the parsed upload, the native fallback parses FileData when nil
+	Series     *pkg.Series
+	ResponseCh chan PredictionResponse
+	// Priority and ClientID decide the request's place in the queue
+	Priority Priority
+	ClientID string
+	// RequestID is the HTTP request that submitted the job, for log correlation
+	RequestID string
+
+	// ctx is cancelled by Cancel or by the shutdown deadline
+	ctx context.Context
+	// flightKey identifies identical requests, see flight
+	flightKey string
+}
+
+// logger tags log lines with the job and the request that submitted it
+func (req PredictionRequest) logger() *slog.Logger {
+	logger := slog.Default()
+	if req.JobID != "" {
+		logger = logger.With("job_id", req.JobID)
+	}
+	if req.RequestID != "" {
+		logger = logger.With("request_id", req.RequestID)
+	}
+	return logger
+}
+
+// PredictionResponse represents a prediction response
+type PredictionResponse struct {
+	Status string      `json:"status"`
+	Data   *Prediction `json:"data"`
+	Error  error       `json:"error,omitempty"`
+}
+
+// Prediction is the test-split forecast returned by the ML backend (or the
+// native fallback), scored against the actual prices
+type Prediction struct {
+	Predictions    []float64            `json:"predictions"`
+	YTest          []float64            `json:"y_test"`
+	Dates          []string             `json:"dates"`
+	Model          string               `json:"model"`
+	Source         string               `json:"source"`
+	FallbackReason string               `json:"fallback_reason,omitempty"`
+	Evaluation     *forecast.Evaluation `json:"evaluation,omitempty"`
+}
+
+// PredictionService runs prediction requests on a pool of workers fed by a
+// priority scheduler
+type PredictionService struct {
+	queue   *scheduler
+	workers int
+	jobs    JobStore
+	ml      *MLClient
+	// fallbacks are tried when the ML backend fails, nil disables the fallback
+	fallbacks []forecast.Forecaster
+
+	// mu guards cancels, the per-job context of every submitted job, and
+	// flights
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	flights map[string]*flight
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	aborted atomic.Int64
+	latency latencyTracker
+	// alive and busy count worker goroutines, for readiness
+	alive atomic.Int32
+	busy  atomic.Int32
+}
+
+// defaultJobLatency stands in for the job latency until one has been observed
+const defaultJobLatency = 5 * time.Second
+
+// latencyTracker keeps an exponentially weighted average of job durations
+type latencyTracker struct {
+	mu  sync.Mutex
+	avg time.Duration
+}
+
+func (l *latencyTracker) observe(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.avg == 0 {
+		l.avg = d
+		return
+	}
+	// Weight recent jobs at 20% so the estimate follows backend slowdowns
+	l.avg += (d - l.avg) / 5
+}
+
+func (l *latencyTracker) average() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.avg == 0 {
+		return defaultJobLatency
+	}
+	return l.avg
+}
+
+// NewPredictionService starts the worker pool, jobs may be nil when the
+// caller only consumes the response channels
+func NewPredictionService(cfg config.Config, jobs JobStore) *PredictionService {
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := cfg.Predictions.Workers
+	ps := &PredictionService{
+		queue: newScheduler(SchedulerConfig{
+			Capacity:           cfg.Predictions.QueueCapacity,
+			MaxPerClient:       cfg.Predictions.MaxPerClient,
+			InteractiveReserve: cfg.Predictions.InteractiveReserve,
+			InteractiveWeight:  cfg.Predictions.InteractiveWeight,
+		}),
+		workers: workers,
+		jobs:    jobs,
+		ml: NewMLClient(cfg.ML.URL, MLClientConfig{
+			Timeout:          cfg.ML.Timeout,
+			MaxRetries:       cfg.ML.MaxRetries,
+			BackoffBase:      cfg.ML.BackoffBase,
+			BackoffMax:       cfg.ML.BackoffMax,
+			BreakerThreshold: cfg.ML.BreakerThreshold,
+			BreakerCooldown:  cfg.ML.BreakerCooldown,
+		}),
+		fallbacks: forecast.Defaults(),
+		cancels:   make(map[string]context.CancelFunc),
+		flights:   make(map[string]*flight),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	// Start worker pool
+	ps.wg.Add(workers)
+	ps.alive.Add(int32(workers))
+	for i := 0; i < workers; i++ {
+		go ps.worker()
+	}
+
+	return ps
+}
+
+func (ps *PredictionService) worker() {
+	defer ps.wg.Done()
+	defer ps.alive.Add(-1)
+	for {
+		req, ok := ps.queue.pop()
+		if !ok {
+			return
+		}
+		ctx := req.ctx
+		if ctx == nil {
+			ctx = ps.ctx
+		}
+		logger := req.logger()
+		ctx = withLogger(ctx, logger)
+		if ctx.Err() != nil {
+			// Cancelled while queued, or the shutdown deadline passed
+			ps.abort(req)
+			continue
+		}
+		ps.setBusy(1)
+		if ps.jobs != nil && req.JobID != "" {
+			if err := ps.jobs.MarkRunning(req.JobID); err != nil {
+				logger.Error("Failed to mark job running", "error", err)
+			}
+		}
+		logger.Debug("Prediction job started", "priority", req.Priority.String(), "client_id", req.ClientID)
+		started := time.Now()
+		result := ps.callFastAPI(ctx, req.FileData, req.FileName)
+		if ctx.Err() != nil {
+			ps.setBusy(-1)
+			ps.abort(req)
+			continue
+		}
+		if result.Status != "success" && len(ps.fallbacks) > 0 {
+			logger.Warn("ML backend unavailable, using native forecasters", "error", result.Error)
+			result = ps.fallbackPrediction(req, result.Error)
+		}
+		if result.Data != nil {
+			result.Data.Evaluation = forecast.Evaluate(result.Data.YTest, result.Data.Predictions)
+		}
+		elapsed := time.Since(started)
+		ps.latency.observe(elapsed)
+		ps.setBusy(-1)
+		if result.Data != nil {
+			logger.Info("Prediction job finished", "status", result.Status, "source", result.Data.Source,
+				"model", result.Data.Model, "duration_ms", elapsed.Milliseconds())
+		} else {
+			logger.Warn("Prediction job failed", "error", result.Error, "duration_ms", elapsed.Milliseconds())
+		}
+		ps.finish(req, result)
+	}
+}
+
+func (ps *PredictionService) setBusy(delta int32) {
+	ps.busy.Add(delta)
+	workersBusy.Add(float64(delta))
+}
+
+// abort ends a request whose context was cancelled, by Cancel or by shutdown
+func (ps *PredictionService) abort(req PredictionRequest) {
+	if ps.ctx.Err() == nil {
+		req.logger().Info("Prediction job cancelled")
+		ps.finish(req, PredictionResponse{Status: "cancelled", Error: ErrJobCancelled})
+		return
+	}
+	ps.aborted.Add(1)
+	req.logger().Warn("Prediction job aborted by shutdown")
+	ps.finish(req, PredictionResponse{Status: "failed", Error: ErrShuttingDown})
+}
+
+// flight is one prediction shared by identical requests. Only the leader is
+// queued and calls the ML backend, followers take no queue slot and receive
+// a copy of its outcome.
+type flight struct {
+	leader    chan PredictionResponse
+	followers []PredictionRequest
+}
+
+// flightKey hashes what the ML backend is sent
+func flightKey(req PredictionRequest) string {
+	h := sha256.New()
+	h.Write([]byte(req.FileName))
+	h.Write([]byte{0})
+	h.Write(req.FileData)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// land ends the flight led by req and returns its followers. A cancelled
+// leader hands the flight to its first follower instead, which is queued in
+// its place.
+func (ps *PredictionService) land(req PredictionRequest, resp PredictionResponse) []PredictionRequest {
+	if req.flightKey == "" {
+		return nil
+	}
+	ps.mu.Lock()
+	f, ok := ps.flights[req.flightKey]
+	if !ok || f.leader != req.ResponseCh {
+		ps.mu.Unlock()
+		return nil
+	}
+	if resp.Status == "cancelled" && len(f.followers) > 0 {
+		next := f.followers[0]
+		f.leader, f.followers = next.ResponseCh, f.followers[1:]
+		ps.mu.Unlock()
+		if err := ps.queue.push(next); err != nil {
+			ps.finish(next, PredictionResponse{Status: "failed", Error: err})
+		}
+		return nil
+	}
+	delete(ps.flights, req.flightKey)
+	ps.mu.Unlock()
+	return f.followers
+}
+
+// dropFollower takes a waiting follower out of its flight
+func (ps *PredictionService) dropFollower(jobID string) (PredictionRequest, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	for _, f := range ps.flights {
+		for i, req := range f.followers {
+			if req.JobID == jobID {
+				f.followers = append(f.followers[:i:i], f.followers[i+1:]...)
+				return req, true
+			}
+		}
+	}
+	return PredictionRequest{}, false
+}
+
+// finish records the outcome on the job (if any) and delivers it to the
+// caller and to any identical request waiting on it
+func (ps *PredictionService) finish(req PredictionRequest, resp PredictionResponse) {
+	followers := ps.land(req, resp)
+	defer func() {
+		for _, follower := range followers {
+			ps.finish(follower, resp)
+		}
+	}()
+
+	jobOutcomes.WithLabelValues(jobOutcome(resp)).Inc()
+	if req.JobID != "" {
+		ps.mu.Lock()
+		if cancel, ok := ps.cancels[req.JobID]; ok {
+			cancel()
+			delete(ps.cancels, req.JobID)
+		}
+		ps.mu.Unlock()
+	}
+	if ps.jobs != nil && req.JobID != "" {
+		if err := ps.jobs.Complete(req.JobID, resp); err != nil {
+			req.logger().Error("Failed to complete job", "error", err)
+		}
+	}
+	req.ResponseCh <- resp
+	close(req.ResponseCh)
+}
+
+func (ps *PredictionService) callFastAPI(ctx context.Context, fileData []byte, fileName string) PredictionResponse {
+	result, err := ps.ml.Predict(ctx, fileData, fileName)
+	if err != nil {
+		loggerFrom(ctx).Warn("FastAPI request failed", "error", err)
+		return PredictionResponse{Status: "failed", Error: err}
+	}
+
+	result.Model = "lstm"
+	result.Source = "ml_backend"
+	return PredictionResponse{Status: "success", Data: result}
+}
+
+// RequestPrediction submits a prediction request and returns a response channel
+func (ps *PredictionService) RequestPrediction(fileData []byte, fileName string) <-chan PredictionResponse {
+	return ps.Submit(PredictionRequest{
+		FileData: fileData,
+		FileName: fileName,
+	})
+}
+
+// Submit enqueues a prepared request, the returned channel receives exactly one response
+func (ps *PredictionService) Submit(req PredictionRequest) <-chan PredictionResponse {
+	ch, _ := ps.Enqueue(req)
+	return ch
+}
+
+// Enqueue is Submit that also reports a rejection synchronously: ErrQueueFull,
+// ErrClientQueueFull or ErrShuttingDown. A rejected request is still answered
+// on the channel and its job marked failed.
+func (ps *PredictionService) Enqueue(req PredictionRequest) (<-chan PredictionResponse, error) {
+	return ps.enqueue(req, ps.queue.push)
+}
+
+// EnqueueWait is Enqueue that waits for room while the queue is full, until
+// ctx is done, rather than rejecting the request at once
+func (ps *PredictionService) EnqueueWait(ctx context.Context, req PredictionRequest) (<-chan PredictionResponse, error) {
+	return ps.enqueue(req, func(req PredictionRequest) error {
+		return ps.queue.pushWait(ctx, req)
+	})
+}
+
+func (ps *PredictionService) enqueue(req PredictionRequest, push func(PredictionRequest) error) (<-chan PredictionResponse, error) {
+	if req.ResponseCh == nil {
+		req.ResponseCh = make(chan PredictionResponse, 1)
+	}
+
+	req.flightKey = flightKey(req)
+
+	ps.mu.Lock()
+	if req.JobID != "" {
+		var cancel context.CancelFunc
+		req.ctx, cancel = context.WithCancel(ps.ctx)
+		ps.cancels[req.JobID] = cancel
+	}
+	if f, ok := ps.flights[req.flightKey]; ok {
+		// An identical upload is already queued or running, share its outcome
+		f.followers = append(f.followers, req)
+		ps.mu.Unlock()
+		predictionsDeduplicated.Inc()
+		req.logger().Info("Prediction joined an identical in-flight request")
+		return req.ResponseCh, nil
+	}
+	ps.flights[req.flightKey] = &flight{leader: req.ResponseCh}
+	ps.mu.Unlock()
+
+	if err := push(req); err != nil {
+		// Queue is full or shutting down, reject request
+		ps.finish(req, PredictionResponse{Status: "failed", Error: err})
+		return req.ResponseCh, err
+	}
+	return req.ResponseCh, nil
+}
+
+// RetryAfter estimates how long until a new request would get a worker: the
+// queue ahead of it, spread over the workers, at the observed job latency
+func (ps *PredictionService) RetryAfter() time.Duration {
+	rounds := ps.queue.len()/max(ps.workers, 1) + 1
+	wait := time.Duration(rounds) * ps.latency.average()
+	return max(wait.Round(time.Second), time.Second)
+}
+
+// Cancel stops a submitted job: a queued one is taken off the queue, a
+// running one has its ML backend call aborted. Either way the job ends as
+// cancelled. It reports false if the job is unknown or already done.
+func (ps *PredictionService) Cancel(jobID string) bool {
+	if req, ok := ps.queue.remove(jobID); ok {
+		ps.finish(req, PredictionResponse{Status: "cancelled", Error: ErrJobCancelled})
+		return true
+	}
+	if req, ok := ps.dropFollower(jobID); ok {
+		ps.finish(req, PredictionResponse{Status: "cancelled", Error: ErrJobCancelled})
+		return true
+	}
+
+	ps.mu.Lock()
+	cancel, ok := ps.cancels[jobID]
+	ps.mu.Unlock()
+	if !ok {
+		return false
+	}
+	cancel()
+	if ps.jobs != nil {
+		// Record the state now rather than when a worker gets to it
+		if err := ps.jobs.Complete(jobID, PredictionResponse{Status: "cancelled", Error: ErrJobCancelled}); err != nil {
+			slog.Error("Failed to cancel job", "job_id", jobID, "error", err)
+		}
+	}
+	return true
+}
+
+// Accepting reports whether new requests will be queued
+func (ps *PredictionService) Accepting() bool {
+	return !ps.queue.isClosed()
+}
+
+// Shutdown stops accepting requests and lets the workers finish the queue.
+// If ctx expires first, in-flight ML calls are cancelled and every job still
+// queued or running is failed with ErrShuttingDown.
+func (ps *PredictionService) Shutdown(ctx context.Context) error {
+	ps.queue.close()
+
+	done := make(chan struct{})
+	go func() {
+		ps.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		ps.cancel()
+		return nil
+	case <-ctx.Done():
+		ps.cancel()
+		<-done
+		return fmt.Errorf("%d prediction job(s) aborted: %w", ps.aborted.Load(), ctx.Err())
+	}
+}
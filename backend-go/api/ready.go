@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Readiness statuses, degraded still serves traffic (e.g. predictions come
+// from the native fallbacks while the ML backend is down)
+const (
+	statusReady    = "ready"
+	statusDegraded = "degraded"
+	statusNotReady = "not_ready"
+)
+
+// healthProbe caches the result of a health check so frequent /ready polls
+// by a load balancer do not turn into a request to the ML backend each
+type healthProbe struct {
+	check   func(ctx context.Context) error
+	ttl     time.Duration
+	timeout time.Duration
+	now     func() time.Time
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+func newHealthProbe(check func(ctx context.Context) error, ttl, timeout time.Duration) *healthProbe {
+	return &healthProbe{check: check, ttl: ttl, timeout: timeout, now: time.Now}
+}
+
+// result returns the cached outcome, running the check when it is stale.
+// Concurrent callers wait for a single check rather than each running one.
+func (p *healthProbe) result(ctx context.Context) (time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.checkedAt.IsZero() && p.now().Sub(p.checkedAt) < p.ttl {
+		return p.checkedAt, p.err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	p.err = p.check(ctx)
+	p.checkedAt = p.now()
+	return p.checkedAt, p.err
+}
+
+// Ready reports whether the service can take traffic. It returns 503 while
+// shutting down, when no worker is left or the prediction queue is full. An
+// unreachable ML backend only degrades the service while fallbacks exist.
+func (h *Handler) Ready(c *gin.Context) {
+	ps := h.predictionService
+	status := statusReady
+	worsen := func(s string) {
+		if s == statusNotReady || status == statusReady {
+			status = s
+		}
+	}
+
+	accepting := ps.Accepting()
+	if !accepting {
+		worsen(statusNotReady)
+	}
+
+	depth, capacity := ps.queue.len(), ps.queue.capacity()
+	queue := gin.H{"status": "ok", "depth": depth, "capacity": capacity}
+	if depth >= capacity {
+		queue["status"] = "saturated"
+		worsen(statusNotReady)
+	}
+
+	alive := int(ps.alive.Load())
+	workers := gin.H{"status": "ok", "alive": alive, "configured": ps.workers, "busy": ps.busy.Load()}
+	switch {
+	case alive == 0:
+		workers["status"] = "down"
+		worsen(statusNotReady)
+	case alive < ps.workers:
+		workers["status"] = "degraded"
+		worsen(statusDegraded)
+	}
+
+	ml := gin.H{"status": "ok"}
+	switch {
+	case ps.ml.baseURL == "":
+		ml["status"] = "disabled"
+	case ps.ml.breaker.open():
+		ml["status"] = "circuit_open"
+	default:
+		checkedAt, err := h.mlProbe.result(c.Request.Context())
+		ml["checked_at"] = checkedAt.UTC().Format(time.RFC3339)
+		if err != nil {
+			ml["status"] = "down"
+			ml["error"] = err.Error()
+		}
+	}
+	if s := ml["status"]; s == "down" || s == "circuit_open" {
+		if len(ps.fallbacks) > 0 {
+			worsen(statusDegraded)
+		} else {
+			worsen(statusNotReady)
+		}
+	}
+
+	code := http.StatusOK
+	if status == statusNotReady {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status": status,
+		"components": gin.H{
+			"accepting":  accepting,
+			"queue":      queue,
+			"workers":    workers,
+			"ml_backend": ml,
+		},
+	})
+}
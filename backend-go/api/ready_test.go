@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getReady calls GET /ready and decodes the body
+func getReady(t *testing.T, h *Handler) (int, map[string]interface{}) {
+	router := gin.New()
+	router.GET("/ready", h.Ready)
+	req, _ := http.NewRequest("GET", "/ready", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w.Code, body
+}
+
+func component(body map[string]interface{}, name string) map[string]interface{} {
+	return body["components"].(map[string]interface{})[name].(map[string]interface{})
+}
+
+func TestReady(t *testing.T) {
+	var probes atomic.Int32
+	healthy := atomic.Bool{}
+	healthy.Store(true)
+	ml := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		assert.Equal(t, "/health", r.URL.Path)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ml.Close()
+
+	cfg := testConfig(2)
+	cfg.ML.URL = ml.URL
+	cfg.Readiness.MLCacheTTL = time.Hour
+	h := newTestHandler(cfg)
+	defer h.predictionService.queue.close()
+
+	code, body := getReady(t, h)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", body["status"])
+	assert.Equal(t, "ok", component(body, "ml_backend")["status"])
+	assert.Equal(t, float64(2), component(body, "workers")["alive"])
+
+	// The probe result is cached
+	healthy.Store(false)
+	getReady(t, h)
+	assert.Equal(t, int32(1), probes.Load())
+
+	// Once stale the ML backend is probed again, fallbacks keep the service up
+	h.mlProbe.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	code, body = getReady(t, h)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "degraded", body["status"])
+	assert.Equal(t, "down", component(body, "ml_backend")["status"])
+	assert.Equal(t, int32(2), probes.Load())
+
+	// Without fallbacks there is nothing left to serve predictions
+	h.predictionService.fallbacks = nil
+	code, body = getReady(t, h)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", body["status"])
+}
+
+func TestReady_QueueSaturated(t *testing.T) {
+	cfg := testConfig(0)
+	cfg.Predictions.QueueCapacity = 1
+	h := newTestHandler(cfg)
+	defer h.predictionService.queue.close()
+
+	code, body := getReady(t, h)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "down", component(body, "workers")["status"])
+	assert.Equal(t, "ok", component(body, "queue")["status"])
+	assert.Equal(t, "disabled", component(body, "ml_backend")["status"])
+
+	require.NoError(t, h.predictionService.queue.push(PredictionRequest{JobID: "queued"}))
+	code, body = getReady(t, h)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "saturated", component(body, "queue")["status"])
+	assert.Equal(t, float64(1), component(body, "queue")["depth"])
+}
+
+func TestReady_ShuttingDown(t *testing.T) {
+	h := newTestHandler(testConfig(1))
+	require.NoError(t, h.Shutdown(context.Background()))
+
+	code, body := getReady(t, h)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, false, body["components"].(map[string]interface{})["accepting"])
+	assert.Equal(t, "down", component(body, "workers")["status"])
+}
+
+func TestHealthProbe_Timeout(t *testing.T) {
+	p := newHealthProbe(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, 0, 10*time.Millisecond)
+
+	_, err := p.result(context.Background())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
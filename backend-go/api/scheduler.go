@@ -0,0 +1,287 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Priority orders prediction requests in the scheduler
+type Priority int
+
+const (
+	// PriorityInteractive is a user waiting on the result, the default
+	PriorityInteractive Priority = iota
+	// PriorityBatch is bulk work that may wait behind interactive requests
+	PriorityBatch
+
+	numPriorities = 2
+)
+
+func (p Priority) String() string {
+	if p == PriorityBatch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// ParsePriority accepts "interactive" or "batch", empty means interactive
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "interactive":
+		return PriorityInteractive, nil
+	case "batch":
+		return PriorityBatch, nil
+	}
+	return 0, fmt.Errorf("invalid priority %q, expected interactive or batch", s)
+}
+
+var (
+	// ErrQueueFull is returned when the scheduler is at capacity
+	ErrQueueFull = errors.New("prediction service busy, try again later")
+	// ErrClientQueueFull is returned when one client holds its share of the queue
+	ErrClientQueueFull = errors.New("prediction service busy, too many queued requests for this client")
+)
+
+// SchedulerConfig sizes the prediction queue, see config.Predictions for
+// the defaults
+type SchedulerConfig struct {
+	// Capacity is the number of requests that may wait for a worker
+	Capacity int
+	// MaxPerClient caps the requests one client may have waiting, 0 disables it
+	MaxPerClient int
+	// InteractiveReserve is how many of the Capacity slots batch requests
+	// may not take
+	InteractiveReserve int
+	// InteractiveWeight is how many interactive requests are served for each
+	// batch request while both are waiting, so batch work is never starved
+	InteractiveWeight int
+}
+
+// scheduler is a bounded queue with priority levels. Within a level clients
+// are served round-robin, so one caller submitting many tickers only gets
+// every n-th slot while others are waiting.
+type scheduler struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	space     *sync.Cond // signalled when a request leaves or the queue closes
+	cfg       SchedulerConfig
+	levels    [numPriorities]fairQueue
+	perClient map[string]int
+	size      int
+	closed    bool
+	// streak counts interactive pops since the last batch pop
+	streak int
+}
+
+// fairQueue holds each client's requests in FIFO order and the clients in
+// round-robin order
+type fairQueue struct {
+	clients []string
+	pending map[string][]PredictionRequest
+}
+
+func newScheduler(cfg SchedulerConfig) *scheduler {
+	s := &scheduler{cfg: cfg, perClient: make(map[string]int)}
+	s.cond = sync.NewCond(&s.mu)
+	s.space = sync.NewCond(&s.mu)
+	for i := range s.levels {
+		s.levels[i].pending = make(map[string][]PredictionRequest)
+	}
+	return s
+}
+
+func (s *scheduler) push(req PredictionRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.admit(req); err != nil {
+		return err
+	}
+	s.add(req)
+	return nil
+}
+
+// pushWait is push that waits for room while the queue or the client's share
+// of it is full, until ctx is done
+func (s *scheduler) pushWait(ctx context.Context, req PredictionRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.space.Broadcast()
+	})
+	defer stop()
+	for {
+		err := s.admit(req)
+		if err == nil {
+			s.add(req)
+			return nil
+		}
+		if !errors.Is(err, ErrQueueFull) && !errors.Is(err, ErrClientQueueFull) || ctx.Err() != nil {
+			return err
+		}
+		s.space.Wait()
+	}
+}
+
+// admit checks the request fits, s.mu is held
+func (s *scheduler) admit(req PredictionRequest) error {
+	if s.closed {
+		return ErrShuttingDown
+	}
+	if s.size >= s.cfg.Capacity {
+		return ErrQueueFull
+	}
+	// Round-robin only orders what was admitted, the limits below keep one
+	// client or a batch from taking every slot in the first place
+	if levelOf(req.Priority) == PriorityBatch && s.size >= s.cfg.Capacity-s.cfg.InteractiveReserve {
+		return ErrQueueFull
+	}
+	if s.cfg.MaxPerClient > 0 && s.perClient[req.ClientID] >= s.cfg.MaxPerClient {
+		return ErrClientQueueFull
+	}
+	return nil
+}
+
+// add queues an admitted request, s.mu is held
+func (s *scheduler) add(req PredictionRequest) {
+	level := &s.levels[levelOf(req.Priority)]
+	if len(level.pending[req.ClientID]) == 0 {
+		level.clients = append(level.clients, req.ClientID)
+	}
+	level.pending[req.ClientID] = append(level.pending[req.ClientID], req)
+	s.perClient[req.ClientID]++
+	s.size++
+	queueDepth.Set(float64(s.size))
+	s.cond.Signal()
+}
+
+// pop blocks until a request is available. It returns false once the
+// scheduler is closed and drained.
+func (s *scheduler) pop() (PredictionRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.size == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.size == 0 {
+		return PredictionRequest{}, false
+	}
+
+	interactive := &s.levels[PriorityInteractive]
+	batch := &s.levels[PriorityBatch]
+	var level *fairQueue
+	if len(interactive.clients) > 0 && (len(batch.clients) == 0 || s.streak < max(s.cfg.InteractiveWeight, 1)) {
+		level = interactive
+		s.streak++
+	} else {
+		level = batch
+		s.streak = 0
+	}
+
+	req := level.next()
+	s.release(req.ClientID)
+	return req, true
+}
+
+// remove takes a queued job out before a worker reaches it
+func (s *scheduler) remove(jobID string) (PredictionRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.levels {
+		if req, ok := s.levels[i].remove(jobID); ok {
+			s.release(req.ClientID)
+			return req, true
+		}
+	}
+	return PredictionRequest{}, false
+}
+
+func (s *scheduler) release(client string) {
+	s.size--
+	queueDepth.Set(float64(s.size))
+	if s.perClient[client]--; s.perClient[client] <= 0 {
+		delete(s.perClient, client)
+	}
+	s.space.Broadcast()
+}
+
+// len is the number of requests waiting for a worker
+func (s *scheduler) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// close stops accepting requests, pop keeps returning what is queued
+func (s *scheduler) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.space.Broadcast()
+}
+
+// capacity is the configured queue bound
+func (s *scheduler) capacity() int {
+	return s.cfg.Capacity
+}
+
+func (s *scheduler) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (q *fairQueue) next() PredictionRequest {
+	client := q.clients[0]
+	reqs := q.pending[client]
+	req := reqs[0]
+	q.clients = q.clients[1:]
+	if len(reqs) > 1 {
+		q.pending[client] = reqs[1:]
+		// Back of the line until every other client has had a turn
+		q.clients = append(q.clients, client)
+	} else {
+		delete(q.pending, client)
+	}
+	return req
+}
+
+func (q *fairQueue) remove(jobID string) (PredictionRequest, bool) {
+	for client, reqs := range q.pending {
+		for i, req := range reqs {
+			if req.JobID != jobID {
+				continue
+			}
+			reqs = append(reqs[:i:i], reqs[i+1:]...)
+			if len(reqs) > 0 {
+				q.pending[client] = reqs
+				return req, true
+			}
+			delete(q.pending, client)
+			for j, c := range q.clients {
+				if c == client {
+					q.clients = append(q.clients[:j:j], q.clients[j+1:]...)
+					break
+				}
+			}
+			return req, true
+		}
+	}
+	return PredictionRequest{}, false
+}
+
+func levelOf(p Priority) Priority {
+	if p < 0 || p >= numPriorities {
+		return PriorityInteractive
+	}
+	return p
+}
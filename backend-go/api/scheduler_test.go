@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drain pops every queued request and returns their job IDs in order
+func drain(s *scheduler) []string {
+	s.close()
+	var ids []string
+	for {
+		req, ok := s.pop()
+		if !ok {
+			return ids
+		}
+		ids = append(ids, req.JobID)
+	}
+}
+
+func TestScheduler_RoundRobinAcrossClients(t *testing.T) {
+	s := newScheduler(SchedulerConfig{Capacity: 10})
+
+	// One client floods the queue before another submits
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, s.push(PredictionRequest{JobID: fmt.Sprintf("a%d", i), ClientID: "a"}))
+	}
+	require.NoError(t, s.push(PredictionRequest{JobID: "b1", ClientID: "b"}))
+	require.NoError(t, s.push(PredictionRequest{JobID: "b2", ClientID: "b"}))
+
+	assert.Equal(t, []string{"a1", "b1", "a2", "b2", "a3", "a4"}, drain(s))
+}
+
+func TestScheduler_PriorityWeight(t *testing.T) {
+	s := newScheduler(SchedulerConfig{Capacity: 10, InteractiveWeight: 2})
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.push(PredictionRequest{JobID: fmt.Sprintf("batch%d", i), Priority: PriorityBatch}))
+	}
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, s.push(PredictionRequest{JobID: fmt.Sprintf("ui%d", i)}))
+	}
+
+	// Interactive work goes first, but batch still gets every third slot
+	assert.Equal(t, []string{"ui1", "ui2", "batch1", "ui3", "ui4", "batch2", "batch3"}, drain(s))
+}
+
+func TestScheduler_Capacity(t *testing.T) {
+	s := newScheduler(SchedulerConfig{Capacity: 3, MaxPerClient: 2})
+
+	require.NoError(t, s.push(PredictionRequest{JobID: "a1", ClientID: "a"}))
+	require.NoError(t, s.push(PredictionRequest{JobID: "a2", ClientID: "a"}))
+	assert.ErrorIs(t, s.push(PredictionRequest{JobID: "a3", ClientID: "a"}), ErrClientQueueFull)
+
+	require.NoError(t, s.push(PredictionRequest{JobID: "b1", ClientID: "b"}))
+	assert.ErrorIs(t, s.push(PredictionRequest{JobID: "c1", ClientID: "c"}), ErrQueueFull)
+
+	// Removing a queued job frees its slot
+	req, ok := s.remove("a1")
+	require.True(t, ok)
+	assert.Equal(t, "a1", req.JobID)
+	assert.Equal(t, 2, s.len())
+	require.NoError(t, s.push(PredictionRequest{JobID: "a3", ClientID: "a"}))
+
+	assert.Equal(t, []string{"a2", "b1", "a3"}, drain(s))
+	assert.ErrorIs(t, s.push(PredictionRequest{JobID: "late"}), ErrShuttingDown)
+}
+
+func TestScheduler_DefaultsAdmitOtherClients(t *testing.T) {
+	def := config.Default().Predictions
+	s := newScheduler(SchedulerConfig{
+		Capacity:           def.QueueCapacity,
+		MaxPerClient:       def.MaxPerClient,
+		InteractiveReserve: def.InteractiveReserve,
+	})
+
+	// One caller submits a 50 ticker batch, only its share is admitted
+	admitted := 0
+	for i := 0; i < 50; i++ {
+		err := s.push(PredictionRequest{JobID: fmt.Sprintf("a%d", i), ClientID: "a", Priority: PriorityBatch})
+		if err == nil {
+			admitted++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrClientQueueFull)
+	}
+	assert.Less(t, admitted, def.QueueCapacity)
+
+	// A second client still gets in
+	require.NoError(t, s.push(PredictionRequest{JobID: "b1", ClientID: "b", Priority: PriorityBatch}))
+	require.NoError(t, s.push(PredictionRequest{JobID: "c1", ClientID: "c"}))
+}
+
+func TestScheduler_PushWait(t *testing.T) {
+	def := config.Default().Predictions
+	s := newScheduler(SchedulerConfig{
+		Capacity:           def.QueueCapacity,
+		MaxPerClient:       def.MaxPerClient,
+		InteractiveReserve: def.InteractiveReserve,
+	})
+	for i := 0; i < def.MaxPerClient; i++ {
+		require.NoError(t, s.push(PredictionRequest{JobID: fmt.Sprintf("a%d", i), ClientID: "a", Priority: PriorityBatch}))
+	}
+
+	// The client's share is full, the next request waits for a pop
+	done := make(chan error, 1)
+	go func() {
+		done <- s.pushWait(context.Background(), PredictionRequest{JobID: "next", ClientID: "a", Priority: PriorityBatch})
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("pushWait returned %v before a slot was free", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	_, ok := s.pop()
+	require.True(t, ok)
+	require.NoError(t, <-done)
+	assert.Equal(t, def.MaxPerClient, s.len())
+
+	// The caller's context bounds the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := s.pushWait(ctx, PredictionRequest{JobID: "late", ClientID: "a", Priority: PriorityBatch})
+	assert.ErrorIs(t, err, ErrClientQueueFull)
+
+	// Closing wakes a waiter
+	go func() {
+		done <- s.pushWait(context.Background(), PredictionRequest{JobID: "closed", ClientID: "a"})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.close()
+	assert.ErrorIs(t, <-done, ErrShuttingDown)
+}
+
+func TestScheduler_InteractiveReserve(t *testing.T) {
+	s := newScheduler(SchedulerConfig{Capacity: 3, InteractiveReserve: 1})
+
+	require.NoError(t, s.push(PredictionRequest{JobID: "b1", ClientID: "a", Priority: PriorityBatch}))
+	require.NoError(t, s.push(PredictionRequest{JobID: "b2", ClientID: "b", Priority: PriorityBatch}))
+	assert.ErrorIs(t, s.push(PredictionRequest{JobID: "b3", ClientID: "c", Priority: PriorityBatch}), ErrQueueFull)
+
+	// The reserved slot is left for interactive work
+	require.NoError(t, s.push(PredictionRequest{JobID: "ui1", ClientID: "c"}))
+	assert.ErrorIs(t, s.push(PredictionRequest{JobID: "ui2", ClientID: "d"}), ErrQueueFull)
+}
+
+func TestParsePriority(t *testing.T) {
+	p, err := ParsePriority("")
+	require.NoError(t, err)
+	assert.Equal(t, PriorityInteractive, p)
+
+	p, err = ParsePriority("Batch")
+	require.NoError(t, err)
+	assert.Equal(t, PriorityBatch, p)
+
+	_, err = ParsePriority("urgent")
+	assert.Error(t, err)
+}
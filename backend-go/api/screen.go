@@ -0,0 +1,183 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+	"github.com/Samudra-G/stockprediction-refactored/screen"
+	"github.com/Samudra-G/stockprediction-refactored/store"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+// screenRequest is the JSON body of POST /screen over stored series, such as
+// {"tickers":["AAPL","MSFT"],"filters":["rsi:14 < 30"],"sort":"rsi:14","order":"asc"}.
+// Without tickers every stored ticker is screened.
+type screenRequest struct {
+	Tickers []string `json:"tickers"`
+	Filters []string `json:"filters"`
+	Sort    string   `json:"sort"`
+	Order   string   `json:"order"`
+	Limit   int      `json:"limit"`
+	All     bool     `json:"all"`
+}
+
+// screenSource is one ticker to screen, load reads its history
+type screenSource struct {
+	ticker string
+	load   func() (*pkg.Series, error)
+}
+
+// screenError is a ticker that could not be screened
+type screenError struct {
+	Ticker string `json:"ticker"`
+	Error  string `json:"error"`
+}
+
+// Screen handles POST /screen. A JSON body screens stored series, a
+// multipart body screens the uploaded "file" parts, read like
+// /metric/batch, with the options as form fields and "filter" repeated.
+// Tickers that match every filter are returned ranked by the sort operand,
+// tickers that cannot be read are listed under errors.
+func (h *Handler) Screen(c *gin.Context) {
+	var (
+		req     screenRequest
+		sources []screenSource
+		// screenAll is set when every stored ticker is screened, which is
+		// not capped like a list the caller sent
+		screenAll bool
+	)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil || len(form.File["file"]) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at least one CSV file is required"})
+			return
+		}
+		req.Filters = form.Value["filter"]
+		req.Sort, req.Order = c.PostForm("sort"), c.PostForm("order")
+		if raw := c.PostForm("limit"); raw != "" {
+			if req.Limit, err = strconv.Atoi(raw); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid limit: %q", raw)})
+				return
+			}
+		}
+		req.All, _ = strconv.ParseBool(c.PostForm("all"))
+		for _, upload := range readBatchUploads(form.File["file"]) {
+			sources = append(sources, screenSource{ticker: upload.ticker, load: func() (*pkg.Series, error) {
+				if upload.err != nil {
+					return nil, upload.err
+				}
+				return h.parseCSVData(c.Request.Context(), upload.data)
+			}})
+		}
+	} else {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body: " + err.Error()})
+			return
+		}
+		if h.series == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "series store is disabled, upload the CSV files instead"})
+			return
+		}
+		tickers := req.Tickers
+		if len(tickers) == 0 {
+			stored, err := h.series.Tickers()
+			if err != nil {
+				loggerFrom(c.Request.Context()).Error("Failed to list stored series", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			tickers, screenAll = stored, true
+		}
+		for _, raw := range tickers {
+			ticker, err := store.NormalizeTicker(raw)
+			if err != nil {
+				ticker = strings.TrimSpace(raw)
+			}
+			sources = append(sources, screenSource{ticker: ticker, load: func() (*pkg.Series, error) {
+				if err != nil {
+					return nil, err
+				}
+				return h.series.Range(ticker, time.Time{}, time.Time{})
+			}})
+		}
+	}
+
+	query, err := screen.Parse(req.Filters, req.Sort, req.Order)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid screen: " + strings.ReplaceAll(err.Error(), "\n", "; ")})
+		return
+	}
+	if req.Limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must not be negative"})
+		return
+	}
+	query.Limit, query.All = req.Limit, req.All
+	if len(sources) > h.batch.MaxTickers && !screenAll {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf(
+			"screen has %d tickers, at most %d are allowed", len(sources), h.batch.MaxTickers)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	rows := make([]*screen.Row, len(sources))
+	failures := make([]error, len(sources))
+	g := &errgroup.Group{}
+	g.SetLimit(h.batch.Workers)
+	for i, src := range sources {
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				failures[i] = errors.New("request cancelled")
+				return nil
+			}
+			series, err := src.load()
+			if err != nil {
+				failures[i] = err
+				return nil
+			}
+			row, err := query.Evaluate(src.ticker, series)
+			if err != nil {
+				failures[i] = err
+				return nil
+			}
+			rows[i] = &row
+			return nil
+		})
+	}
+	g.Wait()
+
+	var (
+		evaluated []screen.Row
+		errs      = []screenError{}
+		matched   int
+	)
+	for i, row := range rows {
+		if failures[i] != nil {
+			errs = append(errs, screenError{Ticker: sources[i].ticker, Error: failures[i].Error()})
+			continue
+		}
+		if row.Matched {
+			matched++
+		}
+		evaluated = append(evaluated, *row)
+	}
+	loggerFrom(ctx).Info("Screened tickers", "tickers", len(sources), "matched", matched, "failed", len(errs))
+
+	filters := make([]string, len(query.Filters))
+	for i, f := range query.Filters {
+		filters[i] = f.String()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"filters":  filters,
+		"columns":  query.Columns(),
+		"rows":     query.Rank(evaluated),
+		"screened": len(evaluated),
+		"matched":  matched,
+		"errors":   errs,
+	})
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postScreen(t *testing.T, router http.Handler, body any) (int, map[string]any) {
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", "/screen", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func screenTickers(resp map[string]any) []string {
+	tickers := []string{}
+	for _, r := range resp["rows"].([]any) {
+		tickers = append(tickers, r.(map[string]any)["ticker"].(string))
+	}
+	return tickers
+}
+
+func TestHandler_Screen_Stored(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.Store.Dir = t.TempDir()
+	h := newTestHandler(cfg)
+	t.Cleanup(func() { h.Shutdown(context.Background()) })
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/metric", h.Metric)
+	router.POST("/screen", h.Screen)
+
+	jan1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	uploadCloses(t, router, "UP", jan1, 10, 11, 12, 13, 14, 15)
+	uploadCloses(t, router, "DOWN", jan1, 15, 14, 13, 12, 11, 10)
+	uploadCloses(t, router, "FAST", jan1, 10, 10, 10, 10, 20, 30)
+
+	code, resp := postScreen(t, router, gin.H{
+		"filters": []string{"close > ma:3"},
+		"sort":    "change(close, 2)",
+	})
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, []string{"FAST", "UP"}, screenTickers(resp))
+	assert.Equal(t, float64(3), resp["screened"])
+	assert.Equal(t, float64(2), resp["matched"])
+	assert.Equal(t, []any{"close", "ma:3", "change(close,2)"}, resp["columns"])
+	first := resp["rows"].([]any)[0].(map[string]any)
+	assert.Equal(t, float64(1), first["rank"])
+	assert.Equal(t, float64(200), first["values"].(map[string]any)["change(close,2)"])
+
+	// An explicit list reports unknown tickers without failing the screen
+	code, resp = postScreen(t, router, gin.H{
+		"tickers": []string{"down", "NOPE"},
+		"filters": []string{"rsi:3 < 30"},
+		"all":     true,
+	})
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, []string{"DOWN"}, screenTickers(resp))
+	errs := resp["errors"].([]any)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "NOPE", errs[0].(map[string]any)["ticker"])
+
+	code, resp = postScreen(t, router, gin.H{"filters": []string{"rsi:14 <"}, "order": "up"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], "order must be asc or desc")
+
+	// Oversized windows are refused before anything is computed
+	code, resp = postScreen(t, router, gin.H{"filters": []string{"ma:2000000000 > 0"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, resp["error"], "at most")
+}
+
+func TestHandler_Screen_Uploaded(t *testing.T) {
+	_, router := setupBatchTest(t)
+
+	w := postFiles(t, router, "/screen", url.Values{
+		"filter": {"close crosses_above 12", "volume >= 0"},
+		"sort":   {"close"},
+	},
+		[2]string{"watchlist.csv", "Date,Close,Ticker\n2023-01-02,11,A\n2023-01-03,13,A\n2023-01-02,11,B\n2023-01-03,11.5,B\n"},
+		[2]string{"C.csv", "Date,Close\n2023-01-02,12\n2023-01-03,20\n"},
+		[2]string{"D.csv", "Date,Open\n2023-01-02,12\n"},
+	)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"C", "A"}, screenTickers(resp))
+	assert.Equal(t, float64(3), resp["screened"])
+	errs := resp["errors"].([]any)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "D", errs[0].(map[string]any)["ticker"])
+
+	// JSON screens need the store, which setupTest disables
+	code, resp := postScreen(t, router, gin.H{"filters": []string{"close > 1"}})
+	assert.Equal(t, http.StatusServiceUnavailable, code, resp)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+	"github.com/Samudra-G/stockprediction-refactored/store"
+	"github.com/gin-gonic/gin"
+)
+
+// storeSeries upserts an uploaded history into the series store and returns
+// the ticker's full stored history, or the upload itself when the store is
+// disabled or fails. A store failure never fails the request.
+func (h *Handler) storeSeries(ctx context.Context, ticker string, series *pkg.Series) *pkg.Series {
+	if h.series == nil {
+		return series
+	}
+	logger := loggerFrom(ctx)
+	res, err := h.series.Upsert(ticker, series.Bars)
+	if err != nil {
+		logger.Warn("Failed to store series", "ticker", ticker, "error", err)
+		return series
+	}
+	logger.Debug("Stored series", "ticker", ticker, "added", res.Added, "replaced", res.Replaced, "unchanged", res.Unchanged, "total", res.Total)
+
+	stored, err := h.series.Range(ticker, time.Time{}, time.Time{})
+	if err != nil || stored.Len() == 0 {
+		return series
+	}
+	return stored
+}
+
+// parseDateParam reads an optional YYYY-MM-DD query parameter
+func parseDateParam(c *gin.Context, name string) (time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s date %q, expected YYYY-MM-DD", name, raw)
+	}
+	return t, nil
+}
+
+// Series handles GET /series/:ticker?from=&to=&indicators=, returning the
+// stored bars in the date range and, when requested, indicators computed
+// over them
+func (h *Handler) Series(c *gin.Context) {
+	if h.series == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "series store is disabled"})
+		return
+	}
+
+	from, err := parseDateParam(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseDateParam(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+	var specs []pkg.Spec
+	if raw := c.Query("indicators"); raw != "" {
+		if specs, err = parseIndicatorSpecs(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	series, err := h.series.Range(c.Param("ticker"), from, to)
+	switch {
+	case errors.Is(err, store.ErrInvalidTicker):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		loggerFrom(c.Request.Context()).Error("Failed to read stored series", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ticker, _ := store.NormalizeTicker(c.Param("ticker"))
+	resp := gin.H{
+		"ticker": ticker,
+		"dates":  seriesDates(series),
+		"bars":   series.Bars,
+	}
+	if len(specs) > 0 {
+		indicators := make(map[string]any, len(specs))
+		for i, v := range computeIndicators(series, specs) {
+			indicators[specs[i].Key] = v
+		}
+		resp["indicators"] = indicators
+	}
+	c.JSON(http.StatusOK, resp)
+}
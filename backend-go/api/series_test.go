@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadCloses posts closes dated from start to /metric for ticker
+func uploadCloses(t *testing.T, router http.Handler, ticker string, start time.Time, closes ...float64) {
+	csvData := "Date,Close"
+	for i, c := range closes {
+		csvData += fmt.Sprintf("\n%s,%v", start.AddDate(0, 0, i).Format("2006-01-02"), c)
+	}
+	body, contentType, err := createMultipartForm(csvData, ticker)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", "/metric", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func getJSON(t *testing.T, router http.Handler, url string) (int, map[string]interface{}) {
+	req, _ := http.NewRequest("GET", url, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func TestHandler_Series(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.Store.Dir = t.TempDir()
+	h := newTestHandler(cfg)
+	defer h.predictionService.queue.close()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/metric", h.Metric)
+	router.GET("/series/:ticker", h.Series)
+	router.POST("/series/:ticker/bars", h.AppendBars)
+
+	jan1 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	uploadCloses(t, router, "AAPL", jan1, 1, 2, 3, 4)
+	// Overlaps the last two days of the first upload
+	uploadCloses(t, router, "aapl", jan1.AddDate(0, 0, 2), 30, 40, 50)
+
+	code, resp := getJSON(t, router, "/series/AAPL")
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, []interface{}{"2023-01-01", "2023-01-02", "2023-01-03", "2023-01-04", "2023-01-05"}, resp["dates"])
+
+	code, resp = getJSON(t, router, "/series/aapl?from=2023-01-02&to=2023-01-04&indicators=ma:2")
+	require.Equal(t, http.StatusOK, code, resp)
+	bars := resp["bars"].([]interface{})
+	require.Len(t, bars, 3)
+	assert.Equal(t, float64(30), bars[1].(map[string]interface{})["close"])
+	ma := resp["indicators"].(map[string]interface{})["ma:2"].([]interface{})
+	assert.Equal(t, []interface{}{nil, float64(16), float64(35)}, ma)
+
+	// Appended bars are stored too, prices left out fall back to the close
+	code, _ = postBars(t, router, "AAPL", `{"bars":[
+		{"date":"2023-01-06","close":60},
+		{"date":"2023-01-07","open":61,"high":65,"low":59,"close":63,"volume":1000}]}`)
+	require.Equal(t, http.StatusOK, code)
+	_, resp = getJSON(t, router, "/series/AAPL?from=2023-01-06")
+	bars = resp["bars"].([]interface{})
+	require.Len(t, bars, 2)
+	closeOnly := bars[0].(map[string]interface{})
+	for _, field := range []string{"open", "high", "low", "adj_close"} {
+		assert.Equal(t, float64(60), closeOnly[field], field)
+	}
+	assert.Equal(t, float64(0), closeOnly["volume"])
+	full := bars[1].(map[string]interface{})
+	assert.Equal(t, []interface{}{61.0, 65.0, 59.0, 63.0, 63.0, 1000.0},
+		[]interface{}{full["open"], full["high"], full["low"], full["close"], full["adj_close"], full["volume"]})
+
+	// The store keys bars by date, so undated bars are refused rather than
+	// only updating the live state
+	code, _ = postBars(t, router, "AAPL", `{"bars":[{"close":64}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, resp = postBars(t, router, "AAPL", `{"bars":[{"date":"2023-01-08","close":64}]}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(8), resp["bars"])
+	code, _ = postBars(t, router, "AAPL", `{"bars":[{"date":"2023-01-09","close":1,"high":"x"}]}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = getJSON(t, router, "/series/MSFT")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = getJSON(t, router, "/series/AAPL?from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = getJSON(t, router, "/series/AAPL?indicators=nope")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestHandler_AppendBars_StoreFailure(t *testing.T) {
+	// The store directory sits under a file, so nothing can be written
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	require.NoError(t, os.WriteFile(blocker, nil, 0o600))
+	cfg := testConfig(1)
+	cfg.Store.Dir = filepath.Join(blocker, "series")
+	h := newTestHandler(cfg)
+	defer h.predictionService.queue.close()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/series/:ticker/bars", h.AppendBars)
+
+	bar := `{"bars":[{"date":"2023-01-02","close":10}]}`
+	code, _ := postBars(t, router, "AAPL", bar)
+	assert.Equal(t, http.StatusInternalServerError, code)
+
+	// The live state did not take the bar, so it can be sent again
+	require.NoError(t, os.Remove(blocker))
+	code, resp := postBars(t, router, "AAPL", bar)
+	require.Equal(t, http.StatusOK, code, resp)
+	assert.Equal(t, float64(1), resp["bars"])
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PollStream pushes job progress as Server-Sent Events until the job finishes
+// or the client goes away. The job is picked by job_id, or the latest job for
+// ticker, the same way Poll resolves it.
+func (h *Handler) PollStream(c *gin.Context) {
+	jobID := c.Query("job_id")
+	if jobID == "" {
+		ticker := c.Query("ticker")
+		if ticker == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ticker or job_id parameter required"})
+			return
+		}
+		latest, exists := h.jobs.Latest(ticker)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no prediction job for ticker"})
+			return
+		}
+		jobID = latest.ID
+	}
+
+	job, updates, unsubscribe, exists := h.jobs.Subscribe(jobID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.SSEvent(string(job.Status), job)
+	c.Writer.Flush()
+	if job.Status.Done() {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case job, ok := <-updates:
+			if !ok {
+				return
+			}
+			c.SSEvent(string(job.Status), job)
+			c.Writer.Flush()
+			if job.Status.Done() {
+				return
+			}
+		case t := <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{"time": t.UTC()})
+			c.Writer.Flush()
+		}
+	}
+}
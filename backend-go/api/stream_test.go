@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents collects the event names of an SSE response until it closes
+func readEvents(t *testing.T, resp *http.Response) []string {
+	t.Helper()
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event:"); ok {
+			events = append(events, strings.TrimSpace(name))
+		}
+	}
+	return events
+}
+
+func TestHandler_PollStream_Progress(t *testing.T) {
+	handler, router := setupTest()
+	handler.streamHeartbeat = 10 * time.Millisecond
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	job, err := handler.jobs.Create("AAPL")
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		handler.jobs.MarkRunning(job.ID)
+		time.Sleep(30 * time.Millisecond)
+		handler.jobs.Complete(job.ID, PredictionResponse{Status: "success", Data: &Prediction{Model: "lstm"}})
+	}()
+
+	resp, err := http.Get(server.URL + "/poll/stream?ticker=AAPL")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+	events := readEvents(t, resp)
+	require.NotEmpty(t, events)
+	assert.Equal(t, "queued", events[0])
+	assert.Contains(t, events, "running")
+	assert.Contains(t, events, "heartbeat")
+	assert.Equal(t, "succeeded", events[len(events)-1])
+}
+
+func TestHandler_PollStream_FinishedJob(t *testing.T) {
+	handler, router := setupTest()
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	job, err := handler.jobs.Create("AAPL")
+	require.NoError(t, err)
+	require.NoError(t, handler.jobs.Complete(job.ID, PredictionResponse{Status: "success"}))
+
+	resp, err := http.Get(server.URL + "/poll/stream?job_id=" + job.ID)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, []string{"succeeded"}, readEvents(t, resp))
+}
+
+func TestHandler_PollStream_UnknownJob(t *testing.T) {
+	_, router := setupTest()
+
+	req, _ := http.NewRequest("GET", "/poll/stream?ticker=NONE", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, _ = http.NewRequest("GET", "/poll/stream", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,301 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+// Side is the direction of an order
+type Side string
+
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
+// Order is a market order emitted by a strategy. A zero Quantity means "as
+// much as possible": all available cash for a buy, the whole position for a
+// sell. The engine is long-only, buys are capped by cash and sells by the
+// position held.
+type Order struct {
+	Side     Side
+	Quantity float64
+}
+
+// Context is what a strategy sees on each bar
+type Context struct {
+	// Index is the position of the current bar in the series
+	Index    int
+	Bar      pkg.Bar
+	Cash     float64
+	Position float64
+}
+
+// Strategy decides orders bar by bar. Init runs once before the replay and
+// may precompute indicators, OnBar must then only look at data up to
+// ctx.Index to avoid lookahead.
+type Strategy interface {
+	Name() string
+	Init(series *pkg.Series) error
+	OnBar(ctx Context) []Order
+}
+
+// Config holds the simulation costs
+type Config struct {
+	InitialCash float64 `json:"initial_cash"`
+	// Commission is a fixed fee per fill
+	Commission float64 `json:"commission"`
+	// CommissionRate is a fee as a fraction of the fill notional
+	CommissionRate float64 `json:"commission_rate"`
+	// Slippage moves every fill price against the trader by this fraction
+	Slippage float64 `json:"slippage"`
+}
+
+// DefaultConfig is a 10,000 account with 0.1% commission and 0.05% slippage
+func DefaultConfig() Config {
+	return Config{
+		InitialCash:    10000,
+		CommissionRate: 0.001,
+		Slippage:       0.0005,
+	}
+}
+
+func (c Config) validate() error {
+	if c.InitialCash <= 0 {
+		return errors.New("initial cash must be positive")
+	}
+	if c.Commission < 0 || c.CommissionRate < 0 || c.Slippage < 0 {
+		return errors.New("commission and slippage must not be negative")
+	}
+	if c.CommissionRate >= 1 || c.Slippage >= 1 {
+		return errors.New("commission rate and slippage must be below 1")
+	}
+	return nil
+}
+
+// Fill is an executed order
+type Fill struct {
+	Time       time.Time `json:"time"`
+	Index      int       `json:"index"`
+	Side       Side      `json:"side"`
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	Commission float64   `json:"commission"`
+}
+
+// Trade is a closed (or partially closed) long position
+type Trade struct {
+	EntryTime  time.Time `json:"entry_time"`
+	ExitTime   time.Time `json:"exit_time"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	Quantity   float64   `json:"quantity"`
+	PnL        float64   `json:"pnl"`
+	Return     float64   `json:"return"`
+}
+
+// EquityPoint is the marked-to-market account value at a bar's close
+type EquityPoint struct {
+	Time     time.Time `json:"time"`
+	Equity   float64   `json:"equity"`
+	Cash     float64   `json:"cash"`
+	Position float64   `json:"position"`
+}
+
+// Result is the outcome of a backtest
+type Result struct {
+	Strategy string        `json:"strategy"`
+	Config   Config        `json:"config"`
+	Stats    Stats         `json:"stats"`
+	Equity   []EquityPoint `json:"equity"`
+	Trades   []Trade       `json:"trades"`
+	Fills    []Fill        `json:"fills"`
+}
+
+// Run replays the series through the strategy. Orders emitted on a bar are
+// filled at the next bar's open, orders on the final bar are dropped.
+func Run(series *pkg.Series, strategy Strategy, cfg Config) (*Result, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if series == nil || series.Len() < 2 {
+		return nil, errors.New("not enough bars to backtest")
+	}
+	if err := strategy.Init(series); err != nil {
+		return nil, fmt.Errorf("failed to initialise strategy %s: %w", strategy.Name(), err)
+	}
+
+	acct := &account{cfg: cfg, cash: cfg.InitialCash}
+	result := &Result{
+		Strategy: strategy.Name(),
+		Config:   cfg,
+		Equity:   make([]EquityPoint, 0, series.Len()),
+	}
+
+	var pending []Order
+	for i, bar := range series.Bars {
+		for _, order := range pending {
+			if fill, ok := acct.execute(order, i, bar); ok {
+				result.Fills = append(result.Fills, fill)
+			}
+		}
+		pending = strategy.OnBar(Context{
+			Index:    i,
+			Bar:      bar,
+			Cash:     acct.cash,
+			Position: acct.position,
+		})
+
+		result.Equity = append(result.Equity, EquityPoint{
+			Time:     bar.Time,
+			Equity:   acct.cash + acct.position*bar.Close,
+			Cash:     acct.cash,
+			Position: acct.position,
+		})
+	}
+
+	result.Trades = acct.trades
+	result.Stats = computeStats(series, result)
+	return result, nil
+}
+
+// account tracks cash and a long position at its average cost
+type account struct {
+	cfg       Config
+	cash      float64
+	position  float64
+	costBasis float64
+	entryTime time.Time
+	trades    []Trade
+}
+
+func (a *account) execute(order Order, i int, bar pkg.Bar) (Fill, bool) {
+	switch order.Side {
+	case Buy:
+		price := bar.Open * (1 + a.cfg.Slippage)
+		if price <= 0 {
+			return Fill{}, false
+		}
+		affordable := (a.cash - a.cfg.Commission) / (price * (1 + a.cfg.CommissionRate))
+		qty := order.Quantity
+		if qty <= 0 || qty > affordable {
+			qty = affordable
+		}
+		if qty <= 0 {
+			return Fill{}, false
+		}
+		commission := a.cfg.Commission + qty*price*a.cfg.CommissionRate
+		a.cash -= qty*price + commission
+		if a.position == 0 {
+			a.entryTime = bar.Time
+		}
+		// Commission is folded into the cost basis so trade PnL is net
+		a.costBasis = (a.costBasis*a.position + qty*price + commission) / (a.position + qty)
+		a.position += qty
+		return Fill{Time: bar.Time, Index: i, Side: Buy, Quantity: qty, Price: price, Commission: commission}, true
+
+	case Sell:
+		qty := order.Quantity
+		if qty <= 0 || qty > a.position {
+			qty = a.position
+		}
+		if qty <= 0 {
+			return Fill{}, false
+		}
+		price := bar.Open * (1 - a.cfg.Slippage)
+		commission := a.cfg.Commission + qty*price*a.cfg.CommissionRate
+		a.cash += qty*price - commission
+		a.position -= qty
+
+		pnl := qty*price - commission - qty*a.costBasis
+		a.trades = append(a.trades, Trade{
+			EntryTime:  a.entryTime,
+			ExitTime:   bar.Time,
+			EntryPrice: a.costBasis,
+			ExitPrice:  price,
+			Quantity:   qty,
+			PnL:        pnl,
+			Return:     pnl / (qty * a.costBasis),
+		})
+		if a.position < 1e-12 {
+			a.position = 0
+			a.costBasis = 0
+		}
+		return Fill{Time: bar.Time, Index: i, Side: Sell, Quantity: qty, Price: price, Commission: commission}, true
+	}
+	return Fill{}, false
+}
+
+// Stats summarises a backtest
+type Stats struct {
+	InitialCash     float64    `json:"initial_cash"`
+	FinalEquity     float64    `json:"final_equity"`
+	TotalReturn     float64    `json:"total_return"`
+	BuyHoldReturn   float64    `json:"buy_hold_return"`
+	MaxDrawdown     float64    `json:"max_drawdown"`
+	NumTrades       int        `json:"num_trades"`
+	WinRate         pkg.Number `json:"win_rate"`
+	AvgTradeReturn  pkg.Number `json:"avg_trade_return"`
+	ProfitFactor    pkg.Number `json:"profit_factor"`
+	Exposure        float64    `json:"exposure"`
+	TotalCommission float64    `json:"total_commission"`
+}
+
+func computeStats(series *pkg.Series, r *Result) Stats {
+	first, last := series.Bars[0], series.Bars[series.Len()-1]
+	final := r.Equity[len(r.Equity)-1].Equity
+
+	stats := Stats{
+		InitialCash: r.Config.InitialCash,
+		FinalEquity: final,
+		TotalReturn: final/r.Config.InitialCash - 1,
+		NumTrades:   len(r.Trades),
+	}
+	if first.Close != 0 {
+		stats.BuyHoldReturn = last.Close/first.Close - 1
+	}
+
+	peak := math.Inf(-1)
+	invested := 0
+	for _, p := range r.Equity {
+		peak = math.Max(peak, p.Equity)
+		if peak > 0 {
+			stats.MaxDrawdown = math.Max(stats.MaxDrawdown, (peak-p.Equity)/peak)
+		}
+		if p.Position > 0 {
+			invested++
+		}
+	}
+	stats.Exposure = float64(invested) / float64(len(r.Equity))
+
+	for _, f := range r.Fills {
+		stats.TotalCommission += f.Commission
+	}
+
+	wins := 0
+	var grossWin, grossLoss, sumReturn float64
+	for _, t := range r.Trades {
+		sumReturn += t.Return
+		if t.PnL > 0 {
+			wins++
+			grossWin += t.PnL
+		} else {
+			grossLoss -= t.PnL
+		}
+	}
+	stats.WinRate = pkg.Number(math.NaN())
+	stats.AvgTradeReturn = pkg.Number(math.NaN())
+	stats.ProfitFactor = pkg.Number(math.NaN())
+	if n := len(r.Trades); n > 0 {
+		stats.WinRate = pkg.Number(float64(wins) / float64(n))
+		stats.AvgTradeReturn = pkg.Number(sumReturn / float64(n))
+		if grossLoss > 0 {
+			stats.ProfitFactor = pkg.Number(grossWin / grossLoss)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,195 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+func almostEqual(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func makeSeries(closes ...float64) *pkg.Series {
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	series := &pkg.Series{}
+	for i, c := range closes {
+		series.Bars = append(series.Bars, pkg.Bar{
+			Time: start.AddDate(0, 0, i), Open: c, High: c, Low: c, Close: c, AdjClose: c,
+		})
+	}
+	return series
+}
+
+// scripted emits fixed orders on given bars
+type scripted map[int][]Order
+
+func (s scripted) Name() string                  { return "scripted" }
+func (s scripted) Init(series *pkg.Series) error { return nil }
+func (s scripted) OnBar(ctx Context) []Order     { return s[ctx.Index] }
+
+func TestRun_FillsNextOpenWithCosts(t *testing.T) {
+	series := makeSeries(100, 100, 110, 120, 120)
+	strategy := scripted{
+		0: {{Side: Buy, Quantity: 10}},
+		2: {{Side: Sell}},
+	}
+	cfg := Config{InitialCash: 2000, Commission: 1, CommissionRate: 0.01, Slippage: 0.01}
+
+	result, err := Run(series, strategy, cfg)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(result.Fills) != 2 {
+		t.Fatalf("len(Fills) = %d, want 2", len(result.Fills))
+	}
+
+	buy, sell := result.Fills[0], result.Fills[1]
+	if buy.Index != 1 || !almostEqual(buy.Price, 101, 1e-9) || !almostEqual(buy.Commission, 1+10.1, 1e-9) {
+		t.Errorf("buy fill = %+v, want index 1 at 101 with 11.1 commission", buy)
+	}
+	if sell.Index != 3 || !almostEqual(sell.Price, 118.8, 1e-9) || sell.Quantity != 10 {
+		t.Errorf("sell fill = %+v, want index 3 at 118.8 for 10", sell)
+	}
+
+	cashAfterBuy := 2000 - 1010 - 11.1
+	sellCommission := 1 + 1188*0.01
+	wantFinal := cashAfterBuy + 1188 - sellCommission
+	if !almostEqual(result.Stats.FinalEquity, wantFinal, 1e-9) {
+		t.Errorf("FinalEquity = %v, want %v", result.Stats.FinalEquity, wantFinal)
+	}
+
+	if len(result.Trades) != 1 {
+		t.Fatalf("len(Trades) = %d, want 1", len(result.Trades))
+	}
+	if !almostEqual(result.Trades[0].PnL, wantFinal-2000, 1e-9) {
+		t.Errorf("trade PnL = %v, want %v", result.Trades[0].PnL, wantFinal-2000)
+	}
+	if result.Stats.NumTrades != 1 || float64(result.Stats.WinRate) != 1 {
+		t.Errorf("stats = %+v, want one winning trade", result.Stats)
+	}
+	if len(result.Equity) != series.Len() {
+		t.Errorf("len(Equity) = %d, want %d", len(result.Equity), series.Len())
+	}
+}
+
+func TestRun_LongOnlyCaps(t *testing.T) {
+	series := makeSeries(100, 100, 100)
+	strategy := scripted{
+		0: {{Side: Sell, Quantity: 5}, {Side: Buy, Quantity: 1000}},
+	}
+
+	result, err := Run(series, strategy, Config{InitialCash: 1000})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// The sell has nothing to close and the buy is capped by cash
+	if len(result.Fills) != 1 || !almostEqual(result.Fills[0].Quantity, 10, 1e-9) {
+		t.Errorf("Fills = %+v, want a single buy of 10", result.Fills)
+	}
+}
+
+func TestRun_MaxDrawdown(t *testing.T) {
+	series := makeSeries(100, 100, 150, 75, 100)
+
+	result, err := Run(series, &BuyAndHold{}, Config{InitialCash: 1000})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !almostEqual(result.Stats.MaxDrawdown, 0.5, 1e-9) {
+		t.Errorf("MaxDrawdown = %v, want 0.5", result.Stats.MaxDrawdown)
+	}
+	if !almostEqual(result.Stats.TotalReturn, 0, 1e-9) {
+		t.Errorf("TotalReturn = %v, want 0", result.Stats.TotalReturn)
+	}
+}
+
+func TestRun_InvalidInput(t *testing.T) {
+	if _, err := Run(makeSeries(100), &BuyAndHold{}, DefaultConfig()); err == nil {
+		t.Error("Run() with one bar should fail")
+	}
+	if _, err := Run(makeSeries(100, 101), &BuyAndHold{}, Config{}); err == nil {
+		t.Error("Run() without initial cash should fail")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"buy_hold", "sma_cross", "rsi", "macd"} {
+		if _, err := New(name, nil); err != nil {
+			t.Errorf("New(%q) error = %v", name, err)
+		}
+	}
+	if _, err := New("sma_cross", map[string]float64{"fast": 50, "slow": 20}); err == nil {
+		t.Error("New(sma_cross) with fast >= slow should fail")
+	}
+	for _, params := range []map[string]float64{
+		{"oversold": math.NaN()},
+		{"overbought": math.NaN()},
+		{"oversold": math.Inf(-1)},
+		{"overbought": math.Inf(1)},
+		{"oversold": 80},
+		{"overbought": 120},
+	} {
+		if _, err := New("rsi", params); err == nil {
+			t.Errorf("New(rsi, %v) should fail", params)
+		}
+	}
+	if _, err := New("magic", nil); err == nil {
+		t.Error("New(magic) should fail")
+	}
+}
+
+func TestSMACrossover_Trades(t *testing.T) {
+	// Down, up, down again: the fast average crosses the slow one twice
+	var closes []float64
+	for i := 0; i < 20; i++ {
+		closes = append(closes, 100-float64(i))
+	}
+	for i := 0; i < 20; i++ {
+		closes = append(closes, 80+2*float64(i))
+	}
+	for i := 0; i < 20; i++ {
+		closes = append(closes, 120-2*float64(i))
+	}
+
+	strategy, err := New("sma_cross", map[string]float64{"fast": 3, "slow": 8})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := Run(makeSeries(closes...), strategy, DefaultConfig())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Stats.NumTrades != 1 {
+		t.Errorf("NumTrades = %d, want 1", result.Stats.NumTrades)
+	}
+}
+
+func TestRSIReversion_Trades(t *testing.T) {
+	// RSI(2) is 100, 100, 33.3, 14.3, 45.5, 77.8, 89.8, 93.4 from bar 2: it
+	// drops below 30 on bar 5 and rises above 70 on bar 7
+	series := makeSeries(100, 101, 102, 103, 101, 99, 100, 102, 104, 105)
+	strategy, err := New("rsi", map[string]float64{"period": 2})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	result, err := Run(series, strategy, DefaultConfig())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(result.Fills) != 2 {
+		t.Fatalf("got %d fills, want 2", len(result.Fills))
+	}
+	if f := result.Fills[0]; f.Side != Buy || f.Index != 6 {
+		t.Errorf("first fill = %s at bar %d, want a buy at bar 6", f.Side, f.Index)
+	}
+	if f := result.Fills[1]; f.Side != Sell || f.Index != 8 {
+		t.Errorf("second fill = %s at bar %d, want a sell at bar 8", f.Side, f.Index)
+	}
+	if len(result.Trades) != 1 || result.Trades[0].PnL <= 0 {
+		t.Errorf("Trades = %+v, want one winning trade", result.Trades)
+	}
+}
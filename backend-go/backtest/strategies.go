@@ -0,0 +1,132 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+// New builds a built-in strategy by name. Missing params take the defaults
+// listed on each strategy.
+func New(name string, params map[string]float64) (Strategy, error) {
+	param := func(key string, def float64) float64 {
+		if v, ok := params[key]; ok {
+			return v
+		}
+		return def
+	}
+
+	switch name {
+	case "buy_hold":
+		return &BuyAndHold{}, nil
+	case "sma_cross":
+		s := &SMACrossover{Fast: int(param("fast", 50)), Slow: int(param("slow", 200))}
+		if s.Fast < 1 || s.Slow <= s.Fast {
+			return nil, fmt.Errorf("sma_cross needs 1 <= fast < slow, got %d/%d", s.Fast, s.Slow)
+		}
+		return s, nil
+	case "rsi":
+		s := &RSIReversion{
+			Period:     int(param("period", 14)),
+			Oversold:   param("oversold", 30),
+			Overbought: param("overbought", 70),
+		}
+		// NaN fails every comparison, so it would otherwise pass and leave
+		// a strategy that never trades
+		level := func(v float64) bool { return !math.IsNaN(v) && !math.IsInf(v, 0) && v >= 0 && v <= 100 }
+		if s.Period < 1 || !level(s.Oversold) || !level(s.Overbought) || s.Oversold >= s.Overbought {
+			return nil, fmt.Errorf("rsi needs period >= 1 and 0 <= oversold < overbought <= 100")
+		}
+		return s, nil
+	case "macd":
+		return &MACDCrossover{}, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q", name)
+}
+
+// BuyAndHold buys on the first bar and never sells, the usual benchmark
+type BuyAndHold struct{}
+
+func (s *BuyAndHold) Name() string                  { return "buy_hold" }
+func (s *BuyAndHold) Init(series *pkg.Series) error { return nil }
+
+func (s *BuyAndHold) OnBar(ctx Context) []Order {
+	if ctx.Index == 0 {
+		return []Order{{Side: Buy}}
+	}
+	return nil
+}
+
+// SMACrossover is long while the fast moving average is above the slow one
+type SMACrossover struct {
+	Fast int
+	Slow int
+
+	fast, slow pkg.Line
+}
+
+func (s *SMACrossover) Name() string { return fmt.Sprintf("sma_cross(%d,%d)", s.Fast, s.Slow) }
+
+func (s *SMACrossover) Init(series *pkg.Series) error {
+	closes := series.Closes()
+	s.fast = pkg.AlignRight(pkg.MovingAverage(closes, s.Fast), len(closes))
+	s.slow = pkg.AlignRight(pkg.MovingAverage(closes, s.Slow), len(closes))
+	return nil
+}
+
+func (s *SMACrossover) OnBar(ctx Context) []Order {
+	return trend(ctx, s.fast[ctx.Index] > s.slow[ctx.Index], s.fast[ctx.Index] < s.slow[ctx.Index])
+}
+
+// RSIReversion buys when RSI drops below Oversold and sells above Overbought
+type RSIReversion struct {
+	Period     int
+	Oversold   float64
+	Overbought float64
+
+	rsi pkg.Line
+}
+
+func (s *RSIReversion) Name() string { return fmt.Sprintf("rsi(%d)", s.Period) }
+
+func (s *RSIReversion) Init(series *pkg.Series) error {
+	closes := series.Closes()
+	s.rsi = pkg.AlignRight(pkg.RSI(closes, s.Period), len(closes))
+	return nil
+}
+
+func (s *RSIReversion) OnBar(ctx Context) []Order {
+	return trend(ctx, s.rsi[ctx.Index] < s.Oversold, s.rsi[ctx.Index] > s.Overbought)
+}
+
+// MACDCrossover is long while the MACD line is above its signal line
+type MACDCrossover struct {
+	macd, signal pkg.Line
+}
+
+func (s *MACDCrossover) Name() string { return "macd" }
+
+func (s *MACDCrossover) Init(series *pkg.Series) error {
+	m, sig, _ := pkg.MACD(series.Closes())
+	s.macd = pkg.MaskWarmup(m, 25)
+	s.signal = pkg.MaskWarmup(sig, 33)
+	return nil
+}
+
+func (s *MACDCrossover) OnBar(ctx Context) []Order {
+	return trend(ctx, s.macd[ctx.Index] > s.signal[ctx.Index], s.macd[ctx.Index] < s.signal[ctx.Index])
+}
+
+// trend enters a full position on enter while flat and exits on exit while
+// long. Comparisons against NaN warm-up values are false, so nothing trades
+// until the indicators exist.
+func trend(ctx Context, enter, exit bool) []Order {
+	switch {
+	case ctx.Position == 0 && enter:
+		return []Order{{Side: Buy}}
+	case ctx.Position > 0 && exit:
+		return []Order{{Side: Sell}}
+	}
+	return nil
+}
@@ -1,27 +1,89 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/Samudra-G/stockprediction-refactored/api"
+	"github.com/Samudra-G/stockprediction-refactored/config"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"golang.org/x/sync/errgroup"
 )
 
 func main() {
 
-	_ = godotenv.Load()
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		config.Usage(os.Stderr)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(api.NewLogger(os.Stdout, cfg.LogLevel))
 
-	h := api.NewHandler()
+	h, err := api.NewHandler(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+		os.Exit(2)
+	}
 
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), api.RequestID(), api.Instrument())
 
 	router.GET("/health", h.Health)
+	router.GET("/ready", h.Ready)
+	router.GET("/metrics", h.Prometheus)
 	router.POST("/metric", h.Metric)
+	router.POST("/metric/batch", h.MetricBatch)
+	router.POST("/backtest", h.Backtest)
 	router.GET("/poll", h.Poll)
+	router.GET("/poll/stream", h.PollStream)
+	router.GET("/jobs/:id", h.Job)
+	router.DELETE("/jobs/:id", h.CancelJob)
+	router.GET("/series/:ticker", h.Series)
+	router.POST("/series/:ticker/bars", h.AppendBars)
+	router.POST("/screen", h.Screen)
+
+	srv := &http.Server{Addr: cfg.Addr, Handler: router}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		slog.Info("Go backend listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+
+	timeout := cfg.ShutdownTimeout
+	slog.Info("Shutting down, waiting for requests and prediction jobs", "timeout", timeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	log.Println("Go backend listening on :8080")
-	if err := router.Run(":8080"); err != nil {
-		log.Fatal(err)
+	// Stop the listener and drain the worker pool at the same time, open
+	// streams and in-flight ML calls share the same deadline
+	var g errgroup.Group
+	g.Go(func() error { return srv.Shutdown(shutdownCtx) })
+	g.Go(func() error { return h.Shutdown(shutdownCtx) })
+	if err := g.Wait(); err != nil {
+		slog.Error("Shutdown incomplete", "error", err)
+		os.Exit(1)
 	}
-}
\ No newline at end of file
+	slog.Info("Shutdown complete")
+}
@@ -0,0 +1,478 @@
+// Package config loads the service settings. Each setting can come from, in
+// increasing order of precedence: its default, a YAML or TOML config file,
+// a .env file, the process environment and a command-line flag.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is every tunable of the Go backend
+type Config struct {
+	Addr            string
+	ShutdownTimeout time.Duration
+	LogLevel        slog.Level
+	StreamHeartbeat time.Duration
+	Readiness       Readiness
+	ML              MLBackend
+	Predictions     Predictions
+	Indicators      Indicators
+	Store           Store
+	Columns         Columns
+	MetricCache     MetricCache
+	Batch           Batch
+}
+
+// Readiness tunes the GET /ready probe of the ML backend
+type Readiness struct {
+	// MLCacheTTL is how long an ML backend health result is reused
+	MLCacheTTL   time.Duration
+	ProbeTimeout time.Duration
+}
+
+// MLBackend is the FastAPI prediction service and how it is called
+type MLBackend struct {
+	// URL is empty when no ML backend is deployed, predictions then always
+	// come from the native forecasters
+	URL              string
+	Timeout          time.Duration
+	MaxRetries       int
+	BackoffBase      time.Duration
+	BackoffMax       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// Predictions sizes the prediction worker pool and its queue
+type Predictions struct {
+	Workers       int
+	QueueCapacity int
+	MaxPerClient  int
+	// InteractiveReserve is the part of the queue only interactive requests
+	// may use, so batch work cannot lock users out
+	InteractiveReserve int
+	InteractiveWeight  int
+	JobTTL             time.Duration
+}
+
+// Indicators are the default windows of the /metric indicators
+type Indicators struct {
+	MAShort   int
+	MALong    int
+	RSIPeriod int
+	// EMAPeriod is the EMA kept up to date by POST /series/{ticker}/bars
+	EMAPeriod int
+}
+
+// Store is the on-disk price history
+type Store struct {
+	// Dir holds one CSV file per ticker, empty disables the store
+	Dir string
+}
+
+// Columns names the CSV header of each price column of an upload, matched
+// case-insensitively. An empty name skips an optional column.
+type Columns struct {
+	Date     string
+	Open     string
+	High     string
+	Low      string
+	Close    string
+	AdjClose string
+	Volume   string
+}
+
+// MetricCache bounds the cache of /metric results
+type MetricCache struct {
+	// MaxEntries of 0 disables the cache
+	MaxEntries int
+	// MaxBytes is the approximate memory budget, 0 for no limit
+	MaxBytes int
+	TTL      time.Duration
+}
+
+// Batch bounds POST /metric/batch
+type Batch struct {
+	// Workers is how many tickers of a batch are computed at once
+	Workers    int
+	MaxTickers int
+}
+
+// Default is the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		Addr:            ":8080",
+		ShutdownTimeout: 30 * time.Second,
+		LogLevel:        slog.LevelInfo,
+		StreamHeartbeat: 15 * time.Second,
+		Readiness: Readiness{
+			MLCacheTTL:   10 * time.Second,
+			ProbeTimeout: 2 * time.Second,
+		},
+		ML: MLBackend{
+			Timeout:          60 * time.Second,
+			MaxRetries:       2,
+			BackoffBase:      500 * time.Millisecond,
+			BackoffMax:       5 * time.Second,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+		},
+		Predictions: Predictions{
+			Workers:            3,
+			QueueCapacity:      10,
+			MaxPerClient:       4,
+			InteractiveReserve: 2,
+			InteractiveWeight:  4,
+			JobTTL:             30 * time.Minute,
+		},
+		Indicators: Indicators{
+			MAShort:   100,
+			MALong:    200,
+			RSIPeriod: 14,
+			EMAPeriod: 20,
+		},
+		Store: Store{Dir: "data/series"},
+		Columns: Columns{
+			Date:     "Date",
+			Open:     "Open",
+			High:     "High",
+			Low:      "Low",
+			Close:    "Close",
+			AdjClose: "Adj Close",
+			Volume:   "Volume",
+		},
+		MetricCache: MetricCache{
+			MaxEntries: 128,
+			MaxBytes:   256 << 20,
+			TTL:        10 * time.Minute,
+		},
+		Batch: Batch{
+			Workers:    4,
+			MaxTickers: 100,
+		},
+	}
+}
+
+// setting ties one field to its config file key and environment variable,
+// the flag name is the key with dots and underscores turned into dashes
+type setting struct {
+	key   string
+	env   string
+	usage string
+	ptr   any
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"addr", "ADDR", "HTTP listen address", &c.Addr},
+		{"shutdown_timeout", "SHUTDOWN_TIMEOUT", "time allowed for in-flight requests and jobs on shutdown", &c.ShutdownTimeout},
+		{"log_level", "LOG_LEVEL", "debug, info, warn or error", &c.LogLevel},
+		{"stream_heartbeat", "STREAM_HEARTBEAT", "interval between SSE heartbeats", &c.StreamHeartbeat},
+		{"readiness.ml_cache_ttl", "READY_ML_CACHE_TTL", "how long an ML backend health check is reused by /ready", &c.Readiness.MLCacheTTL},
+		{"readiness.probe_timeout", "READY_PROBE_TIMEOUT", "timeout of the ML backend health check", &c.Readiness.ProbeTimeout},
+		{"ml_backend.url", "ML_BACKEND", "base URL of the FastAPI prediction service", &c.ML.URL},
+		{"ml_backend.timeout", "ML_TIMEOUT", "timeout of a single ML backend attempt", &c.ML.Timeout},
+		{"ml_backend.max_retries", "ML_MAX_RETRIES", "ML backend retries after the first attempt", &c.ML.MaxRetries},
+		{"ml_backend.backoff_base", "ML_BACKOFF_BASE", "initial retry backoff", &c.ML.BackoffBase},
+		{"ml_backend.backoff_max", "ML_BACKOFF_MAX", "maximum retry backoff", &c.ML.BackoffMax},
+		{"ml_backend.breaker_threshold", "ML_BREAKER_THRESHOLD", "consecutive failures that open the circuit, 0 disables it", &c.ML.BreakerThreshold},
+		{"ml_backend.breaker_cooldown", "ML_BREAKER_COOLDOWN", "how long the circuit stays open", &c.ML.BreakerCooldown},
+		{"predictions.workers", "PREDICTION_WORKERS", "prediction worker goroutines", &c.Predictions.Workers},
+		{"predictions.queue_capacity", "PREDICTION_QUEUE_CAPACITY", "prediction requests that may wait for a worker", &c.Predictions.QueueCapacity},
+		{"predictions.max_per_client", "PREDICTION_MAX_PER_CLIENT", "queued requests allowed per client, 0 for no limit", &c.Predictions.MaxPerClient},
+		{"predictions.interactive_reserve", "PREDICTION_INTERACTIVE_RESERVE", "queue slots batch requests may not take", &c.Predictions.InteractiveReserve},
+		{"predictions.interactive_weight", "PREDICTION_INTERACTIVE_WEIGHT", "interactive requests served per batch request", &c.Predictions.InteractiveWeight},
+		{"predictions.job_ttl", "JOB_TTL", "how long finished jobs stay readable", &c.Predictions.JobTTL},
+		{"indicators.ma_short", "MA_SHORT", "short moving average window", &c.Indicators.MAShort},
+		{"indicators.ma_long", "MA_LONG", "long moving average window", &c.Indicators.MALong},
+		{"indicators.rsi_period", "RSI_PERIOD", "RSI period", &c.Indicators.RSIPeriod},
+		{"indicators.ema_period", "EMA_PERIOD", "EMA period of live bar updates", &c.Indicators.EMAPeriod},
+		{"store.dir", "STORE_DIR", "directory of the stored price history, empty disables it", &c.Store.Dir},
+		{"columns.date", "CSV_DATE_COLUMN", "CSV header of the bar date, empty to ignore dates", &c.Columns.Date},
+		{"columns.open", "CSV_OPEN_COLUMN", "CSV header of the open price", &c.Columns.Open},
+		{"columns.high", "CSV_HIGH_COLUMN", "CSV header of the high price", &c.Columns.High},
+		{"columns.low", "CSV_LOW_COLUMN", "CSV header of the low price", &c.Columns.Low},
+		{"columns.close", "CSV_CLOSE_COLUMN", "CSV header of the close price", &c.Columns.Close},
+		{"columns.adj_close", "CSV_ADJ_CLOSE_COLUMN", "CSV header of the adjusted close", &c.Columns.AdjClose},
+		{"columns.volume", "CSV_VOLUME_COLUMN", "CSV header of the volume", &c.Columns.Volume},
+		{"metric_cache.max_entries", "METRIC_CACHE_ENTRIES", "cached /metric results, 0 disables the cache", &c.MetricCache.MaxEntries},
+		{"metric_cache.max_bytes", "METRIC_CACHE_BYTES", "approximate memory budget of cached /metric results, 0 for no limit", &c.MetricCache.MaxBytes},
+		{"metric_cache.ttl", "METRIC_CACHE_TTL", "how long a /metric result stays cached", &c.MetricCache.TTL},
+		{"batch.workers", "BATCH_WORKERS", "tickers of a /metric/batch request computed concurrently", &c.Batch.Workers},
+		{"batch.max_tickers", "BATCH_MAX_TICKERS", "tickers allowed in one /metric/batch request", &c.Batch.MaxTickers},
+	}
+}
+
+// Load builds the configuration from args (without the program name), the
+// environment, ./.env and the file named by -config or CONFIG_FILE
+func Load(args []string) (Config, error) {
+	return load(args, os.LookupEnv, ".env")
+}
+
+func load(args []string, lookupEnv func(string) (string, bool), dotEnvPath string) (Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet("backend", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", "", "YAML or TOML config file")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(flagName(s.key), "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+	if fs.NArg() > 0 {
+		return cfg, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	// The real environment wins over .env
+	dotEnv := map[string]string{}
+	if dotEnvPath != "" {
+		vals, err := godotenv.Read(dotEnvPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return cfg, fmt.Errorf("reading %s: %w", dotEnvPath, err)
+		}
+		if vals != nil {
+			dotEnv = vals
+		}
+	}
+	env := func(name string) (string, bool) {
+		if v, ok := lookupEnv(name); ok {
+			return v, true
+		}
+		v, ok := dotEnv[name]
+		return v, ok
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = env("CONFIG_FILE")
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		known := make(map[string]bool, len(settings))
+		for _, s := range settings {
+			known[s.key] = true
+		}
+		for _, key := range sortedKeys(values) {
+			if !known[key] {
+				return cfg, fmt.Errorf("%s: unknown setting %q", path, key)
+			}
+		}
+		for _, s := range settings {
+			if v, ok := values[s.key]; ok {
+				if err := s.set(v); err != nil {
+					return cfg, fmt.Errorf("%s: %w", path, err)
+				}
+			}
+		}
+	}
+
+	// A variable set to nothing clears a string setting, e.g. STORE_DIR=
+	// disables the store, other types keep their value
+	for _, s := range settings {
+		v, ok := env(s.env)
+		if _, isString := s.ptr.(*string); ok && (v != "" || isString) {
+			if err := s.set(v); err != nil {
+				return cfg, fmt.Errorf("$%s: %w", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if flagName(s.key) == f.Name && flagErr == nil {
+				if err := s.set(*flagValues[s.key]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", f.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks the settings are usable
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Addr != "", "addr must not be empty")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive")
+	check(c.StreamHeartbeat > 0, "stream_heartbeat must be positive")
+	check(c.Readiness.MLCacheTTL >= 0, "readiness.ml_cache_ttl must not be negative")
+	check(c.Readiness.ProbeTimeout > 0, "readiness.probe_timeout must be positive")
+	if c.ML.URL != "" {
+		u, err := url.Parse(c.ML.URL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"ml_backend.url %q must be an http(s) URL", c.ML.URL)
+	}
+	check(c.ML.Timeout > 0, "ml_backend.timeout must be positive")
+	check(c.ML.MaxRetries >= 0, "ml_backend.max_retries must not be negative")
+	check(c.ML.BackoffBase >= 0 && c.ML.BackoffMax >= c.ML.BackoffBase,
+		"ml_backend backoff must satisfy 0 <= backoff_base <= backoff_max")
+	check(c.ML.BreakerThreshold >= 0, "ml_backend.breaker_threshold must not be negative")
+	check(c.ML.BreakerCooldown >= 0, "ml_backend.breaker_cooldown must not be negative")
+	check(c.Predictions.Workers >= 1, "predictions.workers must be at least 1")
+	check(c.Predictions.QueueCapacity >= 1, "predictions.queue_capacity must be at least 1")
+	check(c.Predictions.MaxPerClient >= 0, "predictions.max_per_client must not be negative")
+	check(c.Predictions.InteractiveReserve >= 0 && c.Predictions.InteractiveReserve < c.Predictions.QueueCapacity,
+		"predictions.interactive_reserve must be at least 0 and below predictions.queue_capacity")
+	check(c.Predictions.InteractiveWeight >= 1, "predictions.interactive_weight must be at least 1")
+	check(c.Predictions.JobTTL > 0, "predictions.job_ttl must be positive")
+	period := func(v int) bool { return v >= 1 && v <= pkg.MaxPeriod }
+	check(period(c.Indicators.MAShort) && period(c.Indicators.MALong),
+		"indicators moving average windows must be between 1 and %d", pkg.MaxPeriod)
+	check(period(c.Indicators.RSIPeriod), "indicators.rsi_period must be between 1 and %d", pkg.MaxPeriod)
+	check(period(c.Indicators.EMAPeriod), "indicators.ema_period must be between 1 and %d", pkg.MaxPeriod)
+	check(strings.TrimSpace(c.Columns.Close) != "", "columns.close must not be empty")
+	check(c.MetricCache.MaxEntries >= 0 && c.MetricCache.MaxBytes >= 0, "metric_cache limits must not be negative")
+	check(c.MetricCache.TTL > 0, "metric_cache.ttl must be positive")
+	check(c.Batch.Workers >= 1, "batch.workers must be at least 1")
+	check(c.Batch.MaxTickers >= 1, "batch.max_tickers must be at least 1")
+
+	return errors.Join(errs...)
+}
+
+// Usage lists every flag with its environment variable and default
+func Usage(w io.Writer) {
+	def := Default()
+	fmt.Fprintln(w, "  -config string\n    \tYAML or TOML config file (env CONFIG_FILE)")
+	for _, s := range def.settings() {
+		fmt.Fprintf(w, "  -%s\n    \t%s (env %s, default %v)\n", flagName(s.key), s.usage, s.env, valueString(s.ptr))
+	}
+}
+
+func (s setting) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	var err error
+	switch p := s.ptr.(type) {
+	case *string:
+		*p = raw
+	case *int:
+		*p, err = strconv.Atoi(raw)
+	case *time.Duration:
+		*p, err = parseDuration(raw)
+	case *slog.Level:
+		err = p.UnmarshalText([]byte(raw))
+	default:
+		err = fmt.Errorf("unsupported type %T", p)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", s.key, raw)
+	}
+	return nil
+}
+
+// parseDuration accepts Go durations ("90s", "1m30s") or plain seconds
+func parseDuration(raw string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(raw, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	return time.ParseDuration(raw)
+}
+
+func valueString(ptr any) string {
+	switch p := ptr.(type) {
+	case *string:
+		return strconv.Quote(*p)
+	case *int:
+		return strconv.Itoa(*p)
+	case *time.Duration:
+		return p.String()
+	case *slog.Level:
+		return strings.ToLower(p.String())
+	}
+	return ""
+}
+
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// readFile decodes a YAML or TOML file into dotted keys, e.g.
+// "ml_backend.url", so nested sections map onto the settings table
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten("", raw, values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, in map[string]any, out map[string]string) error {
+	for k, v := range in {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			if err := flatten(key, v, out); err != nil {
+				return err
+			}
+		case []any:
+			return fmt.Errorf("setting %q must not be a list", key)
+		case nil:
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
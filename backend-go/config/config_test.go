@@ -0,0 +1,173 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func envMap(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := load(nil, envMap(nil), "")
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if cfg.Addr != ":8080" || cfg.Predictions.Workers != 3 || cfg.Predictions.QueueCapacity != 10 {
+		t.Errorf("load() = %+v, want defaults", cfg)
+	}
+	if cfg.ML.Timeout != 60*time.Second || cfg.Indicators.MAShort != 100 || cfg.Indicators.RSIPeriod != 14 {
+		t.Errorf("load() = %+v, want defaults", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "backend.yaml", `
+addr: ":9000"
+log_level: debug
+ml_backend:
+  url: http://file:8000
+  timeout: 10s
+predictions:
+  workers: 5
+  queue_capacity: 20
+indicators:
+  ma_short: 50
+`)
+	dotEnv := writeFile(t, ".env", "ML_BACKEND=http://dotenv:8000\nPREDICTION_WORKERS=6\nRSI_PERIOD=7\n")
+	env := envMap(map[string]string{
+		"CONFIG_FILE":        file,
+		"PREDICTION_WORKERS": "7",
+	})
+
+	cfg, err := load([]string{"-predictions-queue-capacity", "30"}, env, dotEnv)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"addr from file", cfg.Addr, ":9000"},
+		{"log level from file", cfg.LogLevel, slog.LevelDebug},
+		{"timeout from file", cfg.ML.Timeout, 10 * time.Second},
+		{"ma_short from file", cfg.Indicators.MAShort, 50},
+		{".env over file", cfg.ML.URL, "http://dotenv:8000"},
+		{".env over default", cfg.Indicators.RSIPeriod, 7},
+		{"env over .env", cfg.Predictions.Workers, 7},
+		{"flag over file", cfg.Predictions.QueueCapacity, 30},
+		{"untouched default", cfg.Indicators.MALong, 200},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	file := writeFile(t, "backend.toml", `
+shutdown_timeout = 5
+
+[ml_backend]
+max_retries = 0
+backoff_base = "0s"
+
+[predictions]
+job_ttl = "1h"
+`)
+	cfg, err := load([]string{"-config", file}, envMap(nil), "")
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if cfg.ShutdownTimeout != 5*time.Second || cfg.ML.MaxRetries != 0 || cfg.Predictions.JobTTL != time.Hour {
+		t.Errorf("load() = %+v", cfg)
+	}
+}
+
+func TestLoadEmptyEnv(t *testing.T) {
+	file := writeFile(t, "backend.yaml", "ml_backend:\n  url: http://file:8000\n")
+	dotEnv := writeFile(t, ".env", "ML_BACKEND=\n")
+	env := envMap(map[string]string{
+		"CONFIG_FILE":        file,
+		"STORE_DIR":          "",
+		"PREDICTION_WORKERS": "",
+	})
+	cfg, err := load(nil, env, dotEnv)
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if cfg.Store.Dir != "" {
+		t.Errorf("Store.Dir = %q, want empty STORE_DIR to disable the store", cfg.Store.Dir)
+	}
+	if cfg.ML.URL != "" {
+		t.Errorf("ML.URL = %q, want empty ML_BACKEND in .env to override the file", cfg.ML.URL)
+	}
+	if cfg.Predictions.Workers != 3 {
+		t.Errorf("Predictions.Workers = %d, want the default for an empty PREDICTION_WORKERS", cfg.Predictions.Workers)
+	}
+}
+
+func TestLoadColumns(t *testing.T) {
+	file := writeFile(t, "backend.yaml", "columns:\n  date: Day\n  adj_close: Adjusted\n")
+	env := envMap(map[string]string{"CSV_CLOSE_COLUMN": "Last"})
+	cfg, err := load([]string{"-config", file, "-columns-volume", "Shares"}, env, "")
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	want := Columns{Date: "Day", Open: "Open", High: "High", Low: "Low", Close: "Last", AdjClose: "Adjusted", Volume: "Shares"}
+	if cfg.Columns != want {
+		t.Errorf("Columns = %+v, want %+v", cfg.Columns, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{"bad int", nil, map[string]string{"PREDICTION_WORKERS": "many"}, "", "invalid predictions.workers"},
+		{"bad duration flag", []string{"-ml-backend-timeout", "soon"}, nil, "", "invalid ml_backend.timeout"},
+		{"bad log level", nil, map[string]string{"LOG_LEVEL": "loud"}, "", "invalid log_level"},
+		{"unknown key", nil, nil, "predictions:\n  wrokers: 2\n", `unknown setting "predictions.wrokers"`},
+		{"no workers", []string{"-predictions-workers", "0"}, nil, "", "predictions.workers must be at least 1"},
+		{"bad url", nil, map[string]string{"ML_BACKEND": "fastapi:8000"}, "", "must be an http(s) URL"},
+		{"unknown flag", []string{"-nope"}, nil, "", "flag provided but not defined"},
+		{"huge ma window", nil, map[string]string{"MA_LONG": "20000"}, "", "moving average windows must be between 1 and 10000"},
+		{"huge rsi period", []string{"-indicators-rsi-period", "20000"}, nil, "", "indicators.rsi_period must be between 1 and 10000"},
+		{"no close column", []string{"-columns-close", " "}, nil, "", "columns.close must not be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, "c.yaml", tt.file))
+			}
+			_, err := load(args, envMap(tt.env), "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("load() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package forecast
+
+import (
+	"math"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+	"github.com/Samudra-G/stockprediction-refactored/utils"
+)
+
+// Evaluation scores a forecast against the realised values. Metrics that are
+// undefined for the input (e.g. MAPE with zero actuals) are null.
+type Evaluation struct {
+	N                   int        `json:"n"`
+	RMSE                pkg.Number `json:"rmse"`
+	MAE                 pkg.Number `json:"mae"`
+	MAPE                pkg.Number `json:"mape"`
+	SMAPE               pkg.Number `json:"smape"`
+	R2                  pkg.Number `json:"r2"`
+	DirectionalAccuracy pkg.Number `json:"directional_accuracy"`
+	// TheilU is the RMSE relative to a naive forecast that repeats the
+	// previous actual, below 1 means the model beats the naive baseline
+	TheilU pkg.Number `json:"theil_u"`
+}
+
+// Evaluate compares predicted with actual point by point. Percentage metrics
+// are in percent. Directional accuracy and Theil's U need the previous
+// actual, so they are measured from the second point on.
+func Evaluate(actual, predicted []float64) *Evaluation {
+	n := len(actual)
+	if n == 0 || len(predicted) != n {
+		return nil
+	}
+
+	var sumSq, sumAbs, sumPct, sumSym float64
+	pctN, symN := 0, 0
+	for i := range actual {
+		diff := actual[i] - predicted[i]
+		sumSq += diff * diff
+		sumAbs += math.Abs(diff)
+		if actual[i] != 0 {
+			sumPct += math.Abs(diff / actual[i])
+			pctN++
+		}
+		if denom := math.Abs(actual[i]) + math.Abs(predicted[i]); denom != 0 {
+			sumSym += 2 * math.Abs(diff) / denom
+			symN++
+		}
+	}
+
+	mean := utils.Average(actual)
+	var sumTot float64
+	for _, a := range actual {
+		sumTot += (a - mean) * (a - mean)
+	}
+
+	var naiveSq, modelSq float64
+	hits := 0
+	for i := 1; i < n; i++ {
+		naive := actual[i] - actual[i-1]
+		naiveSq += naive * naive
+		model := actual[i] - predicted[i]
+		modelSq += model * model
+		if sign(predicted[i]-actual[i-1]) == sign(actual[i]-actual[i-1]) {
+			hits++
+		}
+	}
+
+	return &Evaluation{
+		N:                   n,
+		RMSE:                pkg.Number(math.Sqrt(sumSq / float64(n))),
+		MAE:                 pkg.Number(sumAbs / float64(n)),
+		MAPE:                pkg.Number(ratio(100*sumPct, float64(pctN))),
+		SMAPE:               pkg.Number(ratio(100*sumSym, float64(symN))),
+		R2:                  pkg.Number(1 - ratio(sumSq, sumTot)),
+		DirectionalAccuracy: pkg.Number(ratio(float64(hits), float64(n-1))),
+		TheilU:              pkg.Number(math.Sqrt(ratio(modelSq, naiveSq))),
+	}
+}
+
+// ratio is num/den, NaN when den is zero
+func ratio(num, den float64) float64 {
+	if den == 0 {
+		return math.NaN()
+	}
+	return num / den
+}
+
+func sign(v float64) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package forecast
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	actual := []float64{10, 12, 11, 13}
+	predicted := []float64{11, 11, 12, 12}
+
+	got := Evaluate(actual, predicted)
+	if got == nil {
+		t.Fatal("Evaluate() = nil")
+	}
+	if got.N != 4 {
+		t.Errorf("N = %d, want 4", got.N)
+	}
+	if !almostEqual(float64(got.RMSE), 1, 1e-9) {
+		t.Errorf("RMSE = %v, want 1", got.RMSE)
+	}
+	if !almostEqual(float64(got.MAE), 1, 1e-9) {
+		t.Errorf("MAE = %v, want 1", got.MAE)
+	}
+	// up (hit), flat prediction against a fall (miss), up (hit)
+	if !almostEqual(float64(got.DirectionalAccuracy), 2.0/3, 1e-9) {
+		t.Errorf("DirectionalAccuracy = %v, want 2/3", got.DirectionalAccuracy)
+	}
+	// naive errors are 2,1,2 and model errors 1,1,1 from the second point
+	if !almostEqual(float64(got.TheilU), math.Sqrt(3.0/9), 1e-9) {
+		t.Errorf("TheilU = %v, want %v", got.TheilU, math.Sqrt(3.0/9))
+	}
+	// sum of squares 4 against total variation 5
+	if !almostEqual(float64(got.R2), 1-4.0/5, 1e-9) {
+		t.Errorf("R2 = %v, want 0.2", got.R2)
+	}
+}
+
+func TestEvaluate_Undefined(t *testing.T) {
+	if Evaluate([]float64{1, 2}, []float64{1}) != nil {
+		t.Error("Evaluate() with mismatched lengths should be nil")
+	}
+
+	got := Evaluate([]float64{0, 0}, []float64{0, 0})
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if !strings.Contains(string(b), `"mape":null`) {
+		t.Errorf("Marshal() = %s, want undefined mape as null", b)
+	}
+}
@@ -0,0 +1,221 @@
+package forecast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Samudra-G/stockprediction-refactored/utils"
+)
+
+// ErrInsufficientData is returned when the history is too short for a model
+var ErrInsufficientData = errors.New("not enough history for forecast")
+
+// Forecaster predicts future values of a series from its history. Models
+// hold only their parameters, so a single value is safe to share across
+// goroutines.
+type Forecaster interface {
+	Name() string
+	Forecast(history []float64, horizon int) ([]float64, error)
+}
+
+// Defaults returns every baseline model with parameters suited to daily
+// closing prices
+func Defaults() []Forecaster {
+	return []Forecaster{
+		Naive{},
+		SeasonalNaive{Period: 5},
+		Drift{},
+		SES{Alpha: 0.5},
+		Holt{Alpha: 0.8, Beta: 0.2},
+		HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.1, Period: 5},
+	}
+}
+
+// Naive repeats the last observed value
+type Naive struct{}
+
+func (Naive) Name() string { return "naive" }
+
+func (Naive) Forecast(history []float64, horizon int) ([]float64, error) {
+	if len(history) == 0 {
+		return nil, ErrInsufficientData
+	}
+	return repeat(history[len(history)-1], horizon), nil
+}
+
+// SeasonalNaive repeats the value observed one period earlier
+type SeasonalNaive struct {
+	Period int
+}
+
+func (m SeasonalNaive) Name() string { return fmt.Sprintf("seasonal_naive(%d)", m.Period) }
+
+func (m SeasonalNaive) Forecast(history []float64, horizon int) ([]float64, error) {
+	if m.Period < 1 || len(history) < m.Period {
+		return nil, ErrInsufficientData
+	}
+	out := make([]float64, horizon)
+	last := history[len(history)-m.Period:]
+	for h := range out {
+		out[h] = last[h%m.Period]
+	}
+	return out, nil
+}
+
+// Drift extends the line through the first and last observations
+type Drift struct{}
+
+func (Drift) Name() string { return "drift" }
+
+func (Drift) Forecast(history []float64, horizon int) ([]float64, error) {
+	n := len(history)
+	if n < 2 {
+		return nil, ErrInsufficientData
+	}
+	slope := (history[n-1] - history[0]) / float64(n-1)
+	out := make([]float64, horizon)
+	for h := range out {
+		out[h] = history[n-1] + float64(h+1)*slope
+	}
+	return out, nil
+}
+
+// SES is simple exponential smoothing, a flat forecast of the smoothed level
+type SES struct {
+	Alpha float64
+}
+
+func (m SES) Name() string { return "ses" }
+
+func (m SES) Forecast(history []float64, horizon int) ([]float64, error) {
+	if len(history) == 0 {
+		return nil, ErrInsufficientData
+	}
+	level := history[0]
+	for _, y := range history[1:] {
+		level = m.Alpha*y + (1-m.Alpha)*level
+	}
+	return repeat(level, horizon), nil
+}
+
+// Holt is Holt's linear trend method
+type Holt struct {
+	Alpha float64
+	Beta  float64
+}
+
+func (m Holt) Name() string { return "holt" }
+
+func (m Holt) Forecast(history []float64, horizon int) ([]float64, error) {
+	if len(history) < 2 {
+		return nil, ErrInsufficientData
+	}
+	level := history[0]
+	trend := history[1] - history[0]
+	for _, y := range history[1:] {
+		prev := level
+		level = m.Alpha*y + (1-m.Alpha)*(level+trend)
+		trend = m.Beta*(level-prev) + (1-m.Beta)*trend
+	}
+	out := make([]float64, horizon)
+	for h := range out {
+		out[h] = level + float64(h+1)*trend
+	}
+	return out, nil
+}
+
+// HoltWinters is additive Holt-Winters with a seasonal cycle of Period bars
+type HoltWinters struct {
+	Alpha  float64
+	Beta   float64
+	Gamma  float64
+	Period int
+}
+
+func (m HoltWinters) Name() string { return fmt.Sprintf("holt_winters(%d)", m.Period) }
+
+func (m HoltWinters) Forecast(history []float64, horizon int) ([]float64, error) {
+	p := m.Period
+	if p < 1 || len(history) < 2*p {
+		return nil, ErrInsufficientData
+	}
+
+	// Initialise from the first two seasons, taking the trend out of the
+	// seasonal offsets and placing the level at the end of the first season
+	first, second := utils.Average(history[:p]), utils.Average(history[p:2*p])
+	trend := (second - first) / float64(p)
+	center := float64(p-1) / 2
+	level := first + center*trend
+	season := make([]float64, p)
+	for i := 0; i < p; i++ {
+		season[i] = history[i] - (first + (float64(i)-center)*trend)
+	}
+
+	for i := p; i < len(history); i++ {
+		y := history[i]
+		s := season[i%p]
+		prev := level
+		level = m.Alpha*(y-s) + (1-m.Alpha)*(level+trend)
+		trend = m.Beta*(level-prev) + (1-m.Beta)*trend
+		season[i%p] = m.Gamma*(y-level) + (1-m.Gamma)*s
+	}
+
+	out := make([]float64, horizon)
+	n := len(history)
+	for h := range out {
+		out[h] = level + float64(h+1)*trend + season[(n+h)%p]
+	}
+	return out, nil
+}
+
+// WalkForward returns one-step-ahead forecasts of data[start:], each made
+// from the history before it, the way the LSTM is scored on its test split
+func WalkForward(f Forecaster, data []float64, start int) ([]float64, error) {
+	if start < 1 || start >= len(data) {
+		return nil, ErrInsufficientData
+	}
+	out := make([]float64, 0, len(data)-start)
+	for i := start; i < len(data); i++ {
+		next, err := f.Forecast(data[:i], 1)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, next[0])
+	}
+	return out, nil
+}
+
+// Select picks the model with the lowest one-step RMSE over data[start:]
+func Select(models []Forecaster, data []float64, start int) (Forecaster, error) {
+	var (
+		best     Forecaster
+		bestRMSE = math.Inf(1)
+	)
+	for _, m := range models {
+		preds, err := WalkForward(m, data, start)
+		if err != nil {
+			continue
+		}
+		var sumSq float64
+		for i, p := range preds {
+			d := data[start+i] - p
+			sumSq += d * d
+		}
+		if rmse := math.Sqrt(sumSq / float64(len(preds))); rmse < bestRMSE {
+			best, bestRMSE = m, rmse
+		}
+	}
+	if best == nil {
+		return nil, ErrInsufficientData
+	}
+	return best, nil
+}
+
+func repeat(v float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = v
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestBaselines(t *testing.T) {
+	history := []float64{1, 2, 3, 4, 5, 6}
+
+	tests := []struct {
+		model Forecaster
+		want  []float64
+	}{
+		{Naive{}, []float64{6, 6}},
+		{SeasonalNaive{Period: 3}, []float64{4, 5}},
+		{Drift{}, []float64{7, 8}},
+		{SES{Alpha: 1}, []float64{6, 6}},
+		{Holt{Alpha: 1, Beta: 1}, []float64{7, 8}},
+		{HoltWinters{Alpha: 0.5, Beta: 0.5, Gamma: 0.5, Period: 2}, []float64{7, 8}},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.model.Forecast(history, 2)
+		if err != nil {
+			t.Errorf("%s: Forecast() error = %v", tt.model.Name(), err)
+			continue
+		}
+		for i := range tt.want {
+			if !almostEqual(got[i], tt.want[i], 1e-9) {
+				t.Errorf("%s: Forecast()[%d] = %v, want %v", tt.model.Name(), i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestInsufficientData(t *testing.T) {
+	for _, m := range Defaults() {
+		if _, err := m.Forecast(nil, 1); err != ErrInsufficientData {
+			t.Errorf("%s: Forecast(nil) error = %v, want ErrInsufficientData", m.Name(), err)
+		}
+	}
+}
+
+func TestWalkForward(t *testing.T) {
+	data := []float64{1, 2, 3, 4, 5}
+
+	got, err := WalkForward(Naive{}, data, 3)
+	if err != nil {
+		t.Fatalf("WalkForward() error = %v", err)
+	}
+	want := []float64{3, 4}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("WalkForward() = %v, want %v", got, want)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	// A straight line is forecast exactly by drift, naive always lags
+	data := make([]float64, 40)
+	for i := range data {
+		data[i] = 10 + 2*float64(i)
+	}
+
+	best, err := Select([]Forecaster{Naive{}, Drift{}}, data, 30)
+	if err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if best.Name() != "drift" {
+		t.Errorf("Select() = %s, want drift", best.Name())
+	}
+}
@@ -35,7 +35,7 @@ func RSI(prices []float64, period int) []float64 {
 		if change > 0 {
 			gains[i-1] = change
 		} else {
-			losses[i-1] = change
+			losses[i-1] = -change
 		}
 	}
 	
@@ -106,22 +106,147 @@ func EMA(data []float64, period int) []float64 {
     return ema
 }
 
-// MACD returns the MACD line, Signal line, and Histogram
+// MACD returns the MACD line, Signal line, and Histogram. All three are as
+// long as data and zero until their warm-up completes: index 25 for the MACD
+// line and index 33 for the signal line and histogram.
 func MACD(data []float64) ([]float64, []float64, []float64) {
-    ema12 := EMA(data, 12)
-    ema26 := EMA(data, 26)
+	return MACDWith(data, 12, 26, 9)
+}
 
-    macdLine := make([]float64, len(data))
-    for i := 0; i < len(data); i++ {
-        macdLine[i] = ema12[i] - ema26[i]
-    }
+// MACDWith is MACD with custom EMA periods, the MACD line warms up at index
+// slow-1 and the signal line and histogram at slow+signal-2.
+func MACDWith(data []float64, fast, slow, signal int) ([]float64, []float64, []float64) {
+	macdLine := make([]float64, len(data))
+	signalLine := make([]float64, len(data))
+	histogram := make([]float64, len(data))
+
+	// The MACD line only exists once the slow EMA is seeded, so the signal
+	// EMA must be run over that part alone
+	start := slow - 1
+	if fast < 1 || signal < 1 || start < 0 || len(data) <= start {
+		return macdLine, signalLine, histogram
+	}
+	emaFast := EMA(data, fast)
+	emaSlow := EMA(data, slow)
+	for i := start; i < len(data); i++ {
+		macdLine[i] = emaFast[i] - emaSlow[i]
+	}
+
+	signalTail := EMA(macdLine[start:], signal)
+	for i := start + signal - 1; i < len(data); i++ {
+		signalLine[i] = signalTail[i-start]
+		histogram[i] = macdLine[i] - signalLine[i]
+	}
 
-    signalLine := EMA(macdLine, 9)
+	return macdLine, signalLine, histogram
+}
 
-    histogram := make([]float64, len(data))
-    for i := 0; i < len(data); i++ {
-        histogram[i] = macdLine[i] - signalLine[i]
-    }
+// Bands holds the upper, middle and lower lines of a channel indicator
+type Bands struct {
+	Upper  Line `json:"upper"`
+	Middle Line `json:"middle"`
+	Lower  Line `json:"lower"`
+}
 
-    return macdLine, signalLine, histogram
-}
\ No newline at end of file
+// BollingerBands returns the moving average of data with bands k standard
+// deviations above and below it. Like MovingAverage, the first value lines
+// up with data[window-1].
+func BollingerBands(data []float64, window int, k float64) Bands {
+	if window < 1 {
+		return Bands{}
+	}
+	middle := MovingAverage(data, window)
+	if middle == nil {
+		return Bands{}
+	}
+
+	upper := make(Line, len(middle))
+	lower := make(Line, len(middle))
+	for i, mean := range middle {
+		var sumSq float64
+		for _, v := range data[i : i+window] {
+			sumSq += (v - mean) * (v - mean)
+		}
+		sd := math.Sqrt(sumSq / float64(window))
+		upper[i] = mean + k*sd
+		lower[i] = mean - k*sd
+	}
+	return Bands{Upper: upper, Middle: middle, Lower: lower}
+}
+
+// ATR returns Wilder's average true range. The result has the same length as
+// the inputs with zeros before index period-1, matching EMA.
+func ATR(high, low, close []float64, period int) []float64 {
+	n := len(close)
+	atr := make([]float64, n)
+	if period < 1 || n < period || len(high) != n || len(low) != n {
+		return atr
+	}
+
+	tr := make([]float64, n)
+	for i := 0; i < n; i++ {
+		tr[i] = high[i] - low[i]
+		if i > 0 {
+			tr[i] = math.Max(tr[i], math.Abs(high[i]-close[i-1]))
+			tr[i] = math.Max(tr[i], math.Abs(low[i]-close[i-1]))
+		}
+	}
+
+	atr[period-1] = utils.Average(tr[:period])
+	for i := period; i < n; i++ {
+		atr[i] = (atr[i-1]*float64(period-1) + tr[i]) / float64(period)
+	}
+	return atr
+}
+
+// KeltnerChannels returns an EMA of close with bands multiplier ATRs away.
+// The series start once both the EMA and the ATR are warmed up, at index
+// max(emaPeriod, atrPeriod)-1 of the input.
+func KeltnerChannels(high, low, close []float64, emaPeriod, atrPeriod int, multiplier float64) Bands {
+	start := max(emaPeriod, atrPeriod) - 1
+	if emaPeriod < 1 || atrPeriod < 1 || len(close) <= start {
+		return Bands{}
+	}
+
+	ema := EMA(close, emaPeriod)
+	atr := ATR(high, low, close, atrPeriod)
+
+	size := len(close) - start
+	bands := Bands{
+		Upper:  make(Line, size),
+		Middle: make(Line, size),
+		Lower:  make(Line, size),
+	}
+	for i := start; i < len(close); i++ {
+		bands.Middle[i-start] = ema[i]
+		bands.Upper[i-start] = ema[i] + multiplier*atr[i]
+		bands.Lower[i-start] = ema[i] - multiplier*atr[i]
+	}
+	return bands
+}
+
+// DonchianChannels returns the highest high and lowest low over each window
+// and their midpoint, aligned like MovingAverage.
+func DonchianChannels(high, low []float64, window int) Bands {
+	if window < 1 || len(high) < window || len(low) != len(high) {
+		return Bands{}
+	}
+
+	size := len(high) - window + 1
+	bands := Bands{
+		Upper:  make(Line, size),
+		Middle: make(Line, size),
+		Lower:  make(Line, size),
+	}
+	for i := 0; i < size; i++ {
+		hi, lo := high[i], low[i]
+		for j := i + 1; j < i+window; j++ {
+			hi = math.Max(hi, high[j])
+			lo = math.Min(lo, low[j])
+		}
+		bands.Upper[i] = hi
+		bands.Lower[i] = lo
+		bands.Middle[i] = (hi + lo) / 2
+	}
+	return bands
+}
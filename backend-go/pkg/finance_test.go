@@ -27,12 +27,49 @@ func TestMovingAverage(t *testing.T) {
 }
 
 func TestRSI(t *testing.T) {
-	data := []float64{44, 44.15, 43.9, 44.35, 44.8, 45, 45.2, 45.05, 44.9, 45.1, 45.3, 45.5, 45.8, 46, 46.2, 46.4}
-	period := 5
+	// Wilder's 14 period example as published by StockCharts
+	data := []float64{
+		44.3389, 44.0902, 44.1497, 43.6124, 44.2779, 44.8264, 45.0955, 45.4245,
+		45.8433, 46.0826, 45.8931, 46.0328, 45.6140, 46.2820, 46.2820, 46.0028,
+		46.0328, 46.4116, 46.2222, 45.6439, 46.2122, 46.2521, 45.7137, 46.4515,
+		45.7835, 45.3548, 44.0288, 44.1783, 44.2181, 44.5672, 43.4205, 42.6628,
+		43.1314,
+	}
+	expected := []float64{
+		70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+		54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+	}
+
+	got := RSI(data, 14)
+	if len(got) != len(expected) {
+		t.Fatalf("len(got) = %d, want %d", len(got), len(expected))
+	}
+	for i := range got {
+		if !almostEqual(got[i], expected[i], 0.01) {
+			t.Errorf("at index %d: got %.4f, want %v", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestRSI_Bounded(t *testing.T) {
+	falling := make([]float64, 30)
+	for i := range falling {
+		falling[i] = 100 - float64(i)
+	}
+	wavy := make([]float64, 200)
+	for i := range wavy {
+		wavy[i] = 100 + 20*math.Sin(float64(i)/3) - float64(i)/10
+	}
 
-	got := RSI(data, period)
-	if len(got) == 0 {
-		t.Errorf("RSI() returned nil or empty, got %v", got)
+	for name, data := range map[string][]float64{"falling": falling, "wavy": wavy} {
+		for i, v := range RSI(data, 14) {
+			if !(v >= 0 && v <= 100) {
+				t.Fatalf("%s: RSI at %d = %v, want a value in [0, 100]", name, i, v)
+			}
+		}
+	}
+	if got := RSI(falling, 14); got[len(got)-1] != 0 {
+		t.Errorf("RSI of a steadily falling series = %v, want 0", got[len(got)-1])
 	}
 }
 
@@ -73,4 +110,105 @@ func TestMACD(t *testing.T) {
 	if len(histogram) != len(data) {
 		t.Errorf("Histogram length = %v, want %v", len(histogram), len(data))
 	}
-}
\ No newline at end of file
+}
+
+func TestBollingerBands(t *testing.T) {
+	data := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+
+	got := BollingerBands(data, 8, 2)
+	if len(got.Middle) != 1 {
+		t.Fatalf("len(middle) = %d, want 1", len(got.Middle))
+	}
+	// population standard deviation of the sample is exactly 2
+	if !almostEqual(got.Middle[0], 5, 1e-9) || !almostEqual(got.Upper[0], 9, 1e-9) || !almostEqual(got.Lower[0], 1, 1e-9) {
+		t.Errorf("BollingerBands() = %+v, want upper 9, middle 5, lower 1", got)
+	}
+
+	if bands := BollingerBands(data, 20, 2); bands.Middle != nil {
+		t.Errorf("BollingerBands() with short data = %+v, want empty", bands)
+	}
+	for _, window := range []int{0, -1} {
+		if bands := BollingerBands(data, window, 2); bands.Middle != nil {
+			t.Errorf("BollingerBands() with window %d = %+v, want empty", window, bands)
+		}
+	}
+}
+
+func TestATR(t *testing.T) {
+	high := []float64{10, 11, 12, 13}
+	low := []float64{8, 9, 10, 11}
+	closes := []float64{9, 10, 11, 12}
+
+	got := ATR(high, low, closes, 2)
+	if len(got) != len(closes) {
+		t.Fatalf("len(got) = %d, want %d", len(got), len(closes))
+	}
+	// TR is 2 on every bar, so ATR settles at 2 as soon as it is seeded
+	for i := 1; i < len(got); i++ {
+		if !almostEqual(got[i], 2, 1e-9) {
+			t.Errorf("at index %d: got %v, want 2", i, got[i])
+		}
+	}
+}
+
+func TestKeltnerChannels(t *testing.T) {
+	high := []float64{10, 11, 12, 13, 14, 15}
+	low := []float64{8, 9, 10, 11, 12, 13}
+	closes := []float64{9, 10, 11, 12, 13, 14}
+
+	got := KeltnerChannels(high, low, closes, 3, 2, 1.5)
+	if len(got.Middle) != 4 {
+		t.Fatalf("len(middle) = %d, want 4", len(got.Middle))
+	}
+	for i := range got.Middle {
+		if !almostEqual(got.Upper[i]-got.Middle[i], 3, 1e-9) || !almostEqual(got.Middle[i]-got.Lower[i], 3, 1e-9) {
+			t.Errorf("at index %d: bands %v/%v/%v not 1.5 ATR apart", i, got.Upper[i], got.Middle[i], got.Lower[i])
+		}
+	}
+}
+
+func TestDonchianChannels(t *testing.T) {
+	high := []float64{5, 7, 6, 8, 4}
+	low := []float64{3, 4, 2, 5, 1}
+
+	got := DonchianChannels(high, low, 3)
+	wantUpper := []float64{7, 8, 8}
+	wantLower := []float64{2, 2, 1}
+	if len(got.Upper) != len(wantUpper) {
+		t.Fatalf("len(upper) = %d, want %d", len(got.Upper), len(wantUpper))
+	}
+	for i := range wantUpper {
+		if got.Upper[i] != wantUpper[i] || got.Lower[i] != wantLower[i] {
+			t.Errorf("at index %d: got %v/%v, want %v/%v", i, got.Upper[i], got.Lower[i], wantUpper[i], wantLower[i])
+		}
+		if !almostEqual(got.Middle[i], (wantUpper[i]+wantLower[i])/2, 1e-9) {
+			t.Errorf("at index %d: middle %v", i, got.Middle[i])
+		}
+	}
+}
+
+func TestMACD_WarmUp(t *testing.T) {
+	data := make([]float64, 60)
+	for i := range data {
+		data[i] = 100
+	}
+
+	macdLine, signalLine, histogram := MACD(data)
+
+	// A flat series has a zero MACD and the signal must not be polluted by
+	// the bars before the slow EMA exists
+	for i := range data {
+		if macdLine[i] != 0 || signalLine[i] != 0 || histogram[i] != 0 {
+			t.Fatalf("at index %d: got %v/%v/%v, want zeros", i, macdLine[i], signalLine[i], histogram[i])
+		}
+	}
+
+	rising := make([]float64, 60)
+	for i := range rising {
+		rising[i] = float64(i)
+	}
+	_, signalLine, _ = MACD(rising)
+	if signalLine[32] != 0 || signalLine[33] == 0 {
+		t.Errorf("signal warm-up: index 32 = %v, index 33 = %v", signalLine[32], signalLine[33])
+	}
+}
@@ -0,0 +1,168 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+)
+
+// The states below update EMA, RSI and MACD one price at a time in O(1) and
+// produce exactly the values of the batch functions at the same bar. Update
+// returns NaN until the indicator is warmed up. A state holds only exported
+// fields, so it is its own snapshot: copy it or encode it as JSON, and call
+// Validate after decoding one.
+
+// EMAState is an incremental EMA, see EMA
+type EMAState struct {
+	Period int     `json:"period"`
+	Count  int     `json:"count"`
+	Sum    float64 `json:"sum"`
+	Value  float64 `json:"value"`
+}
+
+func NewEMAState(period int) *EMAState {
+	return &EMAState{Period: period}
+}
+
+// Update feeds the next price and returns the EMA at it
+func (s *EMAState) Update(price float64) float64 {
+	s.Count++
+	switch {
+	case s.Count < s.Period:
+		s.Sum += price
+		return math.NaN()
+	case s.Count == s.Period:
+		// Seeded with the simple average, like EMA
+		s.Sum += price
+		s.Value = s.Sum / float64(s.Period)
+	default:
+		s.Value = (price-s.Value)*(2.0/float64(s.Period+1)) + s.Value
+	}
+	return s.Value
+}
+
+// Ready reports whether Update has started returning values
+func (s *EMAState) Ready() bool {
+	return s.Count >= s.Period
+}
+
+func (s *EMAState) Validate() error {
+	if s.Period < 1 || s.Count < 0 {
+		return fmt.Errorf("invalid EMA state: period %d, count %d", s.Period, s.Count)
+	}
+	return nil
+}
+
+// RSIState is an incremental RSI using Wilder's smoothing, see RSI
+type RSIState struct {
+	Period int `json:"period"`
+	// Changes counts the price changes seen, one less than the prices
+	Changes   int     `json:"changes"`
+	PrevPrice float64 `json:"prev_price"`
+	AvgGain   float64 `json:"avg_gain"`
+	AvgLoss   float64 `json:"avg_loss"`
+}
+
+func NewRSIState(period int) *RSIState {
+	return &RSIState{Period: period, Changes: -1}
+}
+
+// Update feeds the next price and returns the RSI at it
+func (s *RSIState) Update(price float64) float64 {
+	s.Changes++
+	if s.Changes == 0 {
+		s.PrevPrice = price
+		return math.NaN()
+	}
+
+	change := price - s.PrevPrice
+	s.PrevPrice = price
+	var gain, loss float64
+	if change > 0 {
+		gain = change
+	} else {
+		loss = -change
+	}
+
+	switch {
+	case s.Changes < s.Period:
+		// Until seeded the averages hold running sums
+		s.AvgGain += gain
+		s.AvgLoss += loss
+		return math.NaN()
+	case s.Changes == s.Period:
+		s.AvgGain = (s.AvgGain + gain) / float64(s.Period)
+		s.AvgLoss = (s.AvgLoss + loss) / float64(s.Period)
+	default:
+		s.AvgGain = ((s.AvgGain * float64(s.Period-1)) + gain) / float64(s.Period)
+		s.AvgLoss = ((s.AvgLoss * float64(s.Period-1)) + loss) / float64(s.Period)
+	}
+
+	if s.AvgLoss == 0 {
+		return 100
+	}
+	return 100 - (100 / (1 + s.AvgGain/s.AvgLoss))
+}
+
+// Ready reports whether Update has started returning values
+func (s *RSIState) Ready() bool {
+	return s.Changes >= s.Period
+}
+
+func (s *RSIState) Validate() error {
+	if s.Period < 1 || s.Changes < -1 {
+		return fmt.Errorf("invalid RSI state: period %d, changes %d", s.Period, s.Changes)
+	}
+	return nil
+}
+
+// MACDValue is one bar of MACD output, fields are NaN during their warm-up
+type MACDValue struct {
+	MACD      float64
+	Signal    float64
+	Histogram float64
+}
+
+// MACDState is an incremental MACD, see MACDWith
+type MACDState struct {
+	Fast   EMAState `json:"fast"`
+	Slow   EMAState `json:"slow"`
+	Signal EMAState `json:"signal"`
+}
+
+func NewMACDState(fast, slow, signal int) *MACDState {
+	return &MACDState{
+		Fast:   *NewEMAState(fast),
+		Slow:   *NewEMAState(slow),
+		Signal: *NewEMAState(signal),
+	}
+}
+
+// Update feeds the next price and returns the MACD at it
+func (s *MACDState) Update(price float64) MACDValue {
+	fast := s.Fast.Update(price)
+	slow := s.Slow.Update(price)
+	v := MACDValue{MACD: math.NaN(), Signal: math.NaN(), Histogram: math.NaN()}
+	if !s.Slow.Ready() {
+		return v
+	}
+
+	// The signal EMA only sees the MACD line once the slow EMA is seeded
+	v.MACD = fast - slow
+	if signal := s.Signal.Update(v.MACD); !math.IsNaN(signal) {
+		v.Signal = signal
+		v.Histogram = v.MACD - signal
+	}
+	return v
+}
+
+func (s *MACDState) Validate() error {
+	for _, ema := range []*EMAState{&s.Fast, &s.Slow, &s.Signal} {
+		if err := ema.Validate(); err != nil {
+			return fmt.Errorf("invalid MACD state: %w", err)
+		}
+	}
+	if s.Fast.Period >= s.Slow.Period {
+		return fmt.Errorf("invalid MACD state: fast period %d is not shorter than slow period %d", s.Fast.Period, s.Slow.Period)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// testPrices is a wavy series long enough for every warm-up
+func testPrices(n int) []float64 {
+	prices := make([]float64, n)
+	for i := range prices {
+		prices[i] = 100 + 10*math.Sin(float64(i)/4) + float64(i%3)
+	}
+	return prices
+}
+
+// sameValue treats the batch warm-up (zero or missing) as NaN
+func sameValue(batch, incremental float64, ready bool) bool {
+	if !ready {
+		return math.IsNaN(incremental)
+	}
+	return batch == incremental
+}
+
+func TestEMAState_MatchesBatch(t *testing.T) {
+	prices := testPrices(60)
+	batch := EMA(prices, 10)
+	s := NewEMAState(10)
+	for i, p := range prices {
+		if got := s.Update(p); !sameValue(batch[i], got, i >= 9) {
+			t.Fatalf("at index %d: got %v, want %v", i, got, batch[i])
+		}
+	}
+}
+
+func TestRSIState_MatchesBatch(t *testing.T) {
+	prices := testPrices(60)
+	batch := AlignRight(RSI(prices, 14), len(prices))
+	s := NewRSIState(14)
+	for i, p := range prices {
+		got := s.Update(p)
+		if !sameValue(batch[i], got, i >= 14) {
+			t.Fatalf("at index %d: got %v, want %v", i, got, batch[i])
+		}
+		if i >= 14 && !(got >= 0 && got <= 100) {
+			t.Fatalf("at index %d: got %v, want a value in [0, 100]", i, got)
+		}
+	}
+}
+
+func TestMACDState_MatchesBatch(t *testing.T) {
+	prices := testPrices(80)
+	m, sig, hist := MACDWith(prices, 8, 21, 5)
+	s := NewMACDState(8, 21, 5)
+	for i, p := range prices {
+		got := s.Update(p)
+		if !sameValue(m[i], got.MACD, i >= 20) {
+			t.Fatalf("macd at index %d: got %v, want %v", i, got.MACD, m[i])
+		}
+		if !sameValue(sig[i], got.Signal, i >= 24) || !sameValue(hist[i], got.Histogram, i >= 24) {
+			t.Fatalf("signal at index %d: got %v/%v, want %v/%v", i, got.Signal, got.Histogram, sig[i], hist[i])
+		}
+	}
+}
+
+func TestStates_SnapshotRestore(t *testing.T) {
+	prices := testPrices(60)
+	rsi, macd := NewRSIState(7), NewMACDState(12, 26, 9)
+	for _, p := range prices[:30] {
+		rsi.Update(p)
+		macd.Update(p)
+	}
+
+	data, err := json.Marshal(struct {
+		RSI  *RSIState
+		MACD *MACDState
+	}{rsi, macd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored struct {
+		RSI  RSIState
+		MACD MACDState
+	}
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.RSI.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.MACD.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range prices[30:] {
+		if want, got := rsi.Update(p), restored.RSI.Update(p); want != got {
+			t.Fatalf("rsi at %d: restored %v, want %v", i, got, want)
+		}
+		// %v prints floats exactly and NaN equal to itself
+		if want, got := macd.Update(p), restored.MACD.Update(p); fmt.Sprint(want) != fmt.Sprint(got) {
+			t.Fatalf("macd at %d: restored %v, want %v", i, got, want)
+		}
+	}
+
+	if err := (&MACDState{Fast: *NewEMAState(26), Slow: *NewEMAState(12), Signal: *NewEMAState(9)}).Validate(); err == nil {
+		t.Error("Validate() accepted a fast period longer than the slow one")
+	}
+}
@@ -0,0 +1,401 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Input names a price column an indicator reads
+type Input string
+
+const (
+	InputClose  Input = "close"
+	InputHigh   Input = "high"
+	InputLow    Input = "low"
+	InputVolume Input = "volume"
+)
+
+// Inputs holds the columns an indicator asked for, all of the same length
+type Inputs map[Input][]float64
+
+// InputsFrom extracts the named columns of a series
+func InputsFrom(s *Series, names ...Input) Inputs {
+	in := make(Inputs, len(names))
+	for _, name := range names {
+		if _, ok := in[name]; ok {
+			continue
+		}
+		switch name {
+		case InputClose:
+			in[name] = s.Closes()
+		case InputHigh:
+			in[name] = s.Highs()
+		case InputLow:
+			in[name] = s.Lows()
+		case InputVolume:
+			in[name] = s.Volumes()
+		}
+	}
+	return in
+}
+
+// Param describes one positional indicator parameter. A zero Default makes
+// the parameter required.
+type Param struct {
+	Name    string
+	Default float64
+	Integer bool
+}
+
+// Params are parameter values in the order of the indicator's schema
+type Params []float64
+
+// Int returns parameter i as an integer
+func (p Params) Int(i int) int {
+	return int(p[i])
+}
+
+// Indicator is a technical indicator computed over price columns.
+// Implementations must be safe for concurrent use.
+type Indicator interface {
+	// Name is the short name used in specs, e.g. "ma"
+	Name() string
+	// Params is the parameter schema, every parameter must be positive
+	Params() []Param
+	// Inputs are the columns Compute reads
+	Inputs() []Input
+	// WarmUp is the number of leading bars without a value
+	WarmUp(p Params) int
+	// Outputs names the lines of a multi-line result, in the order of its
+	// JSON fields, and is nil for a result that is a single Line or Number
+	Outputs() []string
+	// Compute returns a Line, Bands, MACDLines or Number. Series are aligned
+	// bar-for-bar with the inputs and NaN during the warm-up.
+	Compute(in Inputs, p Params) any
+}
+
+// MaxPeriod bounds integer parameters such as windows and periods. Longer
+// windows exceed any realistic daily history and only cost memory.
+const MaxPeriod = 10000
+
+var (
+	bandOutputs = []string{"upper", "middle", "lower"}
+	macdOutputs = []string{"macd", "signal", "histogram"}
+)
+
+// ParamValidator is implemented by indicators whose parameters depend on
+// each other, e.g. MACD's fast period must be shorter than its slow one
+type ParamValidator interface {
+	ValidateParams(p Params) error
+}
+
+// MACDLines is the result of the MACD indicator
+type MACDLines struct {
+	MACD      Line `json:"macd"`
+	Signal    Line `json:"signal"`
+	Histogram Line `json:"histogram"`
+}
+
+// Registry holds indicators by name
+type Registry struct {
+	mu         sync.RWMutex
+	indicators map[string]Indicator
+}
+
+func NewRegistry() *Registry {
+	return &Registry{indicators: make(map[string]Indicator)}
+}
+
+// Indicators is the registry of built-in indicators
+var Indicators = newBuiltinRegistry()
+
+// Register adds an indicator, names are case-insensitive and unique
+func (r *Registry) Register(ind Indicator) error {
+	name := strings.ToLower(ind.Name())
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.indicators[name]; ok {
+		return fmt.Errorf("indicator %q is already registered", name)
+	}
+	r.indicators[name] = ind
+	return nil
+}
+
+// MustRegister is Register that panics on a duplicate name
+func (r *Registry) MustRegister(ind Indicator) {
+	if err := r.Register(ind); err != nil {
+		panic(err)
+	}
+}
+
+// Get looks up an indicator by name
+func (r *Registry) Get(name string) (Indicator, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ind, ok := r.indicators[strings.ToLower(name)]
+	return ind, ok
+}
+
+// Names lists the registered indicators in alphabetical order
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.indicators))
+	for name := range r.indicators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Spec is an indicator with validated parameters. Key is the spec as
+// written, e.g. "macd:8/21/5".
+type Spec struct {
+	Key       string
+	Indicator Indicator
+	Params    Params
+}
+
+// Compute runs the indicator over the columns it needs from in
+func (s Spec) Compute(in Inputs) any {
+	return s.Indicator.Compute(in, s.Params)
+}
+
+// WarmUp is the number of leading bars without a value
+func (s Spec) WarmUp() int {
+	return s.Indicator.WarmUp(s.Params)
+}
+
+// ParseSpec parses "name" or "name:p1/p2/...". Trailing parameters may be
+// left out when they have a default.
+func (r *Registry) ParseSpec(entry string) (Spec, error) {
+	entry = strings.TrimSpace(entry)
+	name, rawParams, hasParams := strings.Cut(entry, ":")
+	name = strings.TrimSpace(name)
+	ind, ok := r.Get(name)
+	if !ok {
+		return Spec{}, fmt.Errorf("unknown indicator %q, expected one of %s", strings.ToLower(name), strings.Join(r.Names(), ", "))
+	}
+
+	schema := ind.Params()
+	var values []string
+	if hasParams {
+		values = strings.Split(rawParams, "/")
+	}
+	if len(values) > len(schema) {
+		return Spec{}, fmt.Errorf("takes at most %d parameter(s), got %d", len(schema), len(values))
+	}
+
+	params := make(Params, len(schema))
+	for i, param := range schema {
+		if i >= len(values) {
+			if param.Default == 0 {
+				return Spec{}, fmt.Errorf("%s is required", param.Name)
+			}
+			params[i] = param.Default
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(values[i]), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return Spec{}, fmt.Errorf("%s must be a number, got %q", param.Name, values[i])
+		}
+		if param.Integer && (v != math.Trunc(v) || v < 1) {
+			return Spec{}, fmt.Errorf("%s must be a positive integer, got %q", param.Name, values[i])
+		}
+		if param.Integer && v > MaxPeriod {
+			return Spec{}, fmt.Errorf("%s must be at most %d, got %q", param.Name, MaxPeriod, values[i])
+		}
+		if v <= 0 {
+			return Spec{}, fmt.Errorf("%s must be positive, got %q", param.Name, values[i])
+		}
+		params[i] = v
+	}
+	if v, ok := ind.(ParamValidator); ok {
+		if err := v.ValidateParams(params); err != nil {
+			return Spec{}, err
+		}
+	}
+	return Spec{Key: entry, Indicator: ind, Params: params}, nil
+}
+
+// ParseSpecs parses a comma separated list such as
+// "ma:50,ma:20,rsi:7,macd:8/21/5,bb:20/2". Every problem in the list is
+// reported, not just the first, and a spec may only be listed once.
+func (r *Registry) ParseSpecs(raw string) ([]Spec, error) {
+	var (
+		specs []Spec
+		errs  []error
+		seen  = map[string]bool{}
+	)
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		spec, err := r.ParseSpec(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry, err))
+			continue
+		}
+		if seen[spec.Key] {
+			errs = append(errs, fmt.Errorf("%s: listed twice", entry))
+			continue
+		}
+		seen[spec.Key] = true
+		specs = append(specs, spec)
+	}
+	return specs, errors.Join(errs...)
+}
+
+// indicatorFunc adapts plain functions to Indicator
+type indicatorFunc struct {
+	name     string
+	params   []Param
+	inputs   []Input
+	outputs  []string
+	warmUp   func(p Params) int
+	compute  func(in Inputs, p Params) any
+	validate func(p Params) error
+}
+
+func (f *indicatorFunc) Name() string                    { return f.name }
+func (f *indicatorFunc) Params() []Param                 { return f.params }
+func (f *indicatorFunc) Inputs() []Input                 { return f.inputs }
+func (f *indicatorFunc) Outputs() []string               { return f.outputs }
+func (f *indicatorFunc) WarmUp(p Params) int             { return f.warmUp(p) }
+func (f *indicatorFunc) Compute(in Inputs, p Params) any { return f.compute(in, p) }
+
+func (f *indicatorFunc) ValidateParams(p Params) error {
+	if f.validate == nil {
+		return nil
+	}
+	return f.validate(p)
+}
+
+var (
+	closeOnly = []Input{InputClose}
+	hlc       = []Input{InputHigh, InputLow, InputClose}
+)
+
+func newBuiltinRegistry() *Registry {
+	r := NewRegistry()
+	r.MustRegister(&indicatorFunc{
+		name:   "ma",
+		params: []Param{{Name: "window", Integer: true}},
+		inputs: closeOnly,
+		warmUp: func(p Params) int { return p.Int(0) - 1 },
+		compute: func(in Inputs, p Params) any {
+			closes := in[InputClose]
+			return AlignRight(MovingAverage(closes, p.Int(0)), len(closes))
+		},
+	})
+	r.MustRegister(&indicatorFunc{
+		name:   "ema",
+		params: []Param{{Name: "period", Integer: true}},
+		inputs: closeOnly,
+		warmUp: func(p Params) int { return p.Int(0) - 1 },
+		compute: func(in Inputs, p Params) any {
+			return MaskWarmup(EMA(in[InputClose], p.Int(0)), p.Int(0)-1)
+		},
+	})
+	r.MustRegister(&indicatorFunc{
+		name:   "rsi",
+		params: []Param{{Name: "period", Default: 14, Integer: true}},
+		inputs: closeOnly,
+		warmUp: func(p Params) int { return p.Int(0) },
+		compute: func(in Inputs, p Params) any {
+			closes := in[InputClose]
+			return AlignRight(RSI(closes, p.Int(0)), len(closes))
+		},
+	})
+	r.MustRegister(&indicatorFunc{
+		name: "macd",
+		params: []Param{
+			{Name: "fast", Default: 12, Integer: true},
+			{Name: "slow", Default: 26, Integer: true},
+			{Name: "signal", Default: 9, Integer: true},
+		},
+		inputs:  closeOnly,
+		outputs: macdOutputs,
+		// The MACD line itself is ready at slow-1
+		warmUp: func(p Params) int { return p.Int(1) + p.Int(2) - 2 },
+		validate: func(p Params) error {
+			if p[0] >= p[1] {
+				return errors.New("fast period must be shorter than slow period")
+			}
+			return nil
+		},
+		compute: func(in Inputs, p Params) any {
+			slow, signal := p.Int(1), p.Int(2)
+			m, sig, hist := MACDWith(in[InputClose], p.Int(0), slow, signal)
+			return MACDLines{
+				MACD:      MaskWarmup(m, slow-1),
+				Signal:    MaskWarmup(sig, slow+signal-2),
+				Histogram: MaskWarmup(hist, slow+signal-2),
+			}
+		},
+	})
+	r.MustRegister(&indicatorFunc{
+		name:   "vol",
+		inputs: closeOnly,
+		warmUp: func(Params) int { return 1 },
+		compute: func(in Inputs, _ Params) any {
+			return Number(Volatility(in[InputClose]))
+		},
+	})
+	r.MustRegister(&indicatorFunc{
+		name: "bb",
+		params: []Param{
+			{Name: "window", Default: 20, Integer: true},
+			{Name: "k", Default: 2},
+		},
+		inputs:  closeOnly,
+		outputs: bandOutputs,
+		warmUp:  func(p Params) int { return p.Int(0) - 1 },
+		compute: func(in Inputs, p Params) any {
+			closes := in[InputClose]
+			return BollingerBands(closes, p.Int(0), p[1]).AlignRight(len(closes))
+		},
+	})
+	r.MustRegister(&indicatorFunc{
+		name:   "atr",
+		params: []Param{{Name: "period", Default: 14, Integer: true}},
+		inputs: hlc,
+		warmUp: func(p Params) int { return p.Int(0) - 1 },
+		compute: func(in Inputs, p Params) any {
+			return MaskWarmup(ATR(in[InputHigh], in[InputLow], in[InputClose], p.Int(0)), p.Int(0)-1)
+		},
+	})
+	r.MustRegister(&indicatorFunc{
+		name: "keltner",
+		params: []Param{
+			{Name: "ema_period", Default: 20, Integer: true},
+			{Name: "atr_period", Default: 10, Integer: true},
+			{Name: "multiplier", Default: 2},
+		},
+		inputs:  hlc,
+		outputs: bandOutputs,
+		warmUp:  func(p Params) int { return max(p.Int(0), p.Int(1)) - 1 },
+		compute: func(in Inputs, p Params) any {
+			closes := in[InputClose]
+			return KeltnerChannels(in[InputHigh], in[InputLow], closes, p.Int(0), p.Int(1), p[2]).AlignRight(len(closes))
+		},
+	})
+	r.MustRegister(&indicatorFunc{
+		name:    "donchian",
+		params:  []Param{{Name: "window", Default: 20, Integer: true}},
+		inputs:  []Input{InputHigh, InputLow},
+		outputs: bandOutputs,
+		warmUp:  func(p Params) int { return p.Int(0) - 1 },
+		compute: func(in Inputs, p Params) any {
+			highs := in[InputHigh]
+			return DonchianChannels(highs, in[InputLow], p.Int(0)).AlignRight(len(highs))
+		},
+	})
+	return r
+}
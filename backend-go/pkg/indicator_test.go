@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_ParseSpecs(t *testing.T) {
+	specs, err := Indicators.ParseSpecs("ma:50, RSI ,macd:8/21,bb:20/2.5,vol")
+	if err != nil {
+		t.Fatalf("ParseSpecs() error = %v", err)
+	}
+	if len(specs) != 5 {
+		t.Fatalf("len(specs) = %d, want 5", len(specs))
+	}
+
+	want := []struct {
+		key, name string
+		params    Params
+	}{
+		{"ma:50", "ma", Params{50}},
+		// Missing trailing parameters take their defaults
+		{"RSI", "rsi", Params{14}},
+		{"macd:8/21", "macd", Params{8, 21, 9}},
+		{"bb:20/2.5", "bb", Params{20, 2.5}},
+		{"vol", "vol", Params{}},
+	}
+	for i, w := range want {
+		got := specs[i]
+		if got.Key != w.key || got.Indicator.Name() != w.name || !reflect.DeepEqual(got.Params, w.params) {
+			t.Errorf("spec %d = %q %s %v, want %q %s %v", i, got.Key, got.Indicator.Name(), got.Params, w.key, w.name, w.params)
+		}
+	}
+}
+
+func TestRegistry_ParseSpecsErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown":          "sma:20",
+		"missing required": "ma",
+		"too many":         "rsi:14/3",
+		"not a number":     "ma:abc",
+		"fractional":       "ma:2.5",
+		"zero":             "ema:0",
+		"negative k":       "bb:20/-1",
+		"fast not faster":  "macd:26/12/9",
+		"duplicate":        "ma:20,ma:20",
+		"huge window":      "ma:2000000000",
+		"huge period":      "macd:12/26/99999",
+	}
+	for name, raw := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Indicators.ParseSpecs(raw); err == nil {
+				t.Errorf("ParseSpecs(%q) succeeded, want an error", raw)
+			}
+		})
+	}
+
+	// Every bad entry is reported
+	_, err := Indicators.ParseSpecs("foo,ma:20,rsi:0")
+	if err == nil || !strings.Contains(err.Error(), "foo") || !strings.Contains(err.Error(), "rsi:0") || strings.Contains(err.Error(), "ma:20") {
+		t.Errorf("ParseSpecs() error = %v", err)
+	}
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	double := &indicatorFunc{
+		name:   "Double",
+		inputs: closeOnly,
+		warmUp: func(Params) int { return 0 },
+		compute: func(in Inputs, _ Params) any {
+			out := make(Line, len(in[InputClose]))
+			for i, v := range in[InputClose] {
+				out[i] = 2 * v
+			}
+			return out
+		},
+	}
+	if err := r.Register(double); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := r.Register(double); err == nil {
+		t.Error("registering a name twice succeeded")
+	}
+
+	spec, err := r.ParseSpec("double")
+	if err != nil {
+		t.Fatalf("ParseSpec() error = %v", err)
+	}
+	got := spec.Compute(Inputs{InputClose: {1, 2}}).(Line)
+	if !reflect.DeepEqual(got, Line{2, 4}) {
+		t.Errorf("Compute() = %v, want [2 4]", got)
+	}
+}
+
+// Every built-in is NaN exactly for the warm-up it reports
+func TestBuiltinIndicators_WarmUp(t *testing.T) {
+	n := 80
+	series := &Series{Bars: make([]Bar, n)}
+	for i := range series.Bars {
+		c := 100 + 10*math.Sin(float64(i)/5)
+		series.Bars[i] = Bar{High: c + 1, Low: c - 1, Close: c}
+	}
+
+	for _, raw := range []string{"ma:10", "ema:10", "rsi:7", "macd:8/21/5", "bb:20/2", "atr:14", "keltner:20/10/2", "donchian:20"} {
+		spec, err := Indicators.ParseSpec(raw)
+		if err != nil {
+			t.Fatalf("ParseSpec(%q) error = %v", raw, err)
+		}
+		var (
+			line    Line
+			outputs []string
+		)
+		switch v := spec.Compute(InputsFrom(series, spec.Indicator.Inputs()...)).(type) {
+		case Line:
+			line = v
+		case Bands:
+			line, outputs = v.Middle, bandOutputs
+		case MACDLines:
+			line, outputs = v.Signal, macdOutputs
+		default:
+			t.Fatalf("%s: unexpected result %T", raw, v)
+		}
+		if len(line) != n {
+			t.Fatalf("%s: len = %d, want %d", raw, len(line), n)
+		}
+		if got := spec.Indicator.Outputs(); !reflect.DeepEqual(got, outputs) {
+			t.Errorf("%s: Outputs() = %v, want %v", raw, got, outputs)
+		}
+		warmUp := spec.WarmUp()
+		for i, v := range line {
+			if (i < warmUp) != math.IsNaN(v) {
+				t.Errorf("%s: bar %d is %v with warm-up %d", raw, i, v, warmUp)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// Line is an indicator series aligned bar-for-bar with its input. NaN marks
+// bars where the indicator is not defined yet and is encoded as JSON null.
+type Line []float64
+
+func (l Line) MarshalJSON() ([]byte, error) {
+	if l == nil {
+		return []byte("null"), nil
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, len(l)*8))
+	buf.WriteByte('[')
+	for i, v := range l {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			buf.WriteString("null")
+			continue
+		}
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+func (l *Line) UnmarshalJSON(data []byte) error {
+	var raw []*float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		*l = nil
+		return nil
+	}
+	out := make(Line, len(raw))
+	for i, v := range raw {
+		if v == nil {
+			out[i] = math.NaN()
+		} else {
+			out[i] = *v
+		}
+	}
+	*l = out
+	return nil
+}
+
+// AlignRight pads a trimmed series (as returned by MovingAverage or RSI) at
+// the front with NaN so its last value lines up with the last of n bars.
+func AlignRight(values []float64, n int) Line {
+	out := make(Line, n)
+	offset := n - len(values)
+	for i := range out {
+		if i < offset {
+			out[i] = math.NaN()
+		} else {
+			out[i] = values[i-offset]
+		}
+	}
+	return out
+}
+
+// MaskWarmup copies a full-length series (as returned by EMA or MACD) and
+// replaces its first warmup values with NaN.
+func MaskWarmup(values []float64, warmup int) Line {
+	out := make(Line, len(values))
+	for i, v := range values {
+		if i < warmup {
+			out[i] = math.NaN()
+		} else {
+			out[i] = v
+		}
+	}
+	return out
+}
+
+// AlignRight aligns every line of the bands to n bars
+func (b Bands) AlignRight(n int) Bands {
+	return Bands{
+		Upper:  AlignRight(b.Upper, n),
+		Middle: AlignRight(b.Middle, n),
+		Lower:  AlignRight(b.Lower, n),
+	}
+}
+
+// Number is a scalar result that may be undefined, NaN and infinities are
+// encoded as JSON null
+type Number float64
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	v := float64(n)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return []byte("null"), nil
+	}
+	return []byte(strconv.FormatFloat(v, 'g', -1, 64)), nil
+}
+
+func (n *Number) UnmarshalJSON(data []byte) error {
+	var v *float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v == nil {
+		*n = Number(math.NaN())
+	} else {
+		*n = Number(*v)
+	}
+	return nil
+}
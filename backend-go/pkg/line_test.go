@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestLineJSON(t *testing.T) {
+	line := Line{math.NaN(), 1.5, 2}
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(b) != "[null,1.5,2]" {
+		t.Errorf("Marshal() = %s, want [null,1.5,2]", b)
+	}
+
+	var back Line
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(back) != 3 || !math.IsNaN(back[0]) || back[2] != 2 {
+		t.Errorf("Unmarshal() = %v, want [NaN 1.5 2]", back)
+	}
+}
+
+func TestAlignRight(t *testing.T) {
+	got := AlignRight(MovingAverage([]float64{1, 2, 3, 4, 5}, 3), 5)
+	if len(got) != 5 {
+		t.Fatalf("len(got) = %d, want 5", len(got))
+	}
+	if !math.IsNaN(got[0]) || !math.IsNaN(got[1]) || got[2] != 2 || got[4] != 4 {
+		t.Errorf("AlignRight() = %v, want [NaN NaN 2 3 4]", got)
+	}
+
+	if all := AlignRight(nil, 3); !math.IsNaN(all[0]) || !math.IsNaN(all[2]) {
+		t.Errorf("AlignRight(nil) = %v, want all NaN", all)
+	}
+}
+
+func TestMaskWarmup(t *testing.T) {
+	got := MaskWarmup(EMA([]float64{1, 2, 3, 4}, 3), 2)
+	if !math.IsNaN(got[0]) || !math.IsNaN(got[1]) || got[2] != 2 {
+		t.Errorf("MaskWarmup() = %v, want [NaN NaN 2 ...]", got)
+	}
+}
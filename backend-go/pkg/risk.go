@@ -0,0 +1,262 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/utils"
+)
+
+// RiskConfig controls the risk report
+type RiskConfig struct {
+	// RiskFreeRate is annual, e.g. 0.02 for 2%
+	RiskFreeRate   float64
+	PeriodsPerYear int
+	// Confidence levels for VaR/CVaR, each in (0, 1)
+	Confidence []float64
+	// Simulations is the number of Monte Carlo draws, Seed makes them repeatable
+	Simulations int
+	Seed        uint64
+}
+
+// DefaultRiskConfig uses daily bars, no risk-free rate and 95%/99% VaR
+func DefaultRiskConfig() RiskConfig {
+	return RiskConfig{
+		PeriodsPerYear: 252,
+		Confidence:     []float64{0.95, 0.99},
+		Simulations:    10000,
+		Seed:           1,
+	}
+}
+
+func (c RiskConfig) Validate() error {
+	if c.PeriodsPerYear < 1 {
+		return fmt.Errorf("periods per year must be positive")
+	}
+	if c.RiskFreeRate <= -1 {
+		return fmt.Errorf("risk-free rate must be above -100%%")
+	}
+	for _, conf := range c.Confidence {
+		if conf <= 0 || conf >= 1 {
+			return fmt.Errorf("confidence level %v must be between 0 and 1", conf)
+		}
+	}
+	if c.Simulations < 0 {
+		return fmt.Errorf("simulations must not be negative")
+	}
+	return nil
+}
+
+// Drawdown is the largest peak-to-trough fall of a price series
+type Drawdown struct {
+	MaxDrawdown Number     `json:"max_drawdown"`
+	PeakIndex   int        `json:"peak_index"`
+	TroughIndex int        `json:"trough_index"`
+	PeakDate    *time.Time `json:"peak_date,omitempty"`
+	TroughDate  *time.Time `json:"trough_date,omitempty"`
+}
+
+// TailRisk is a one-period value at risk and its expected shortfall, both
+// expressed as positive fractional losses
+type TailRisk struct {
+	VaR  Number `json:"var"`
+	CVaR Number `json:"cvar"`
+}
+
+// VaRReport holds the three VaR estimates at one confidence level
+type VaRReport struct {
+	Confidence float64  `json:"confidence"`
+	Historical TailRisk `json:"historical"`
+	Parametric TailRisk `json:"parametric"`
+	MonteCarlo TailRisk `json:"monte_carlo"`
+}
+
+// RiskReport summarises the risk of holding a price series
+type RiskReport struct {
+	AnnualizedReturn     Number      `json:"annualized_return"`
+	AnnualizedVolatility Number      `json:"annualized_volatility"`
+	Sharpe               Number      `json:"sharpe"`
+	Sortino              Number      `json:"sortino"`
+	Calmar               Number      `json:"calmar"`
+	Drawdown             Drawdown    `json:"drawdown"`
+	VaR                  []VaRReport `json:"var"`
+}
+
+// Returns computes simple period returns of prices
+func Returns(prices []float64) []float64 {
+	if len(prices) < 2 {
+		return nil
+	}
+	returns := make([]float64, len(prices)-1)
+	for i := 1; i < len(prices); i++ {
+		returns[i-1] = (prices[i] - prices[i-1]) / prices[i-1]
+	}
+	return returns
+}
+
+// MaxDrawdown finds the deepest fall from a running peak. times may be nil,
+// otherwise it must line up with prices.
+func MaxDrawdown(prices []float64, times []time.Time) Drawdown {
+	dd := Drawdown{}
+	if len(prices) == 0 {
+		dd.MaxDrawdown = Number(math.NaN())
+		return dd
+	}
+
+	peakIdx := 0
+	for i, p := range prices {
+		if p > prices[peakIdx] {
+			peakIdx = i
+		}
+		if prices[peakIdx] <= 0 {
+			continue
+		}
+		if fall := (prices[peakIdx] - p) / prices[peakIdx]; fall > float64(dd.MaxDrawdown) {
+			dd.MaxDrawdown = Number(fall)
+			dd.PeakIndex = peakIdx
+			dd.TroughIndex = i
+		}
+	}
+	if len(times) == len(prices) && !times[0].IsZero() {
+		peak, trough := times[dd.PeakIndex], times[dd.TroughIndex]
+		dd.PeakDate = &peak
+		dd.TroughDate = &trough
+	}
+	return dd
+}
+
+// Risk builds the full risk report for a price series
+func Risk(prices []float64, times []time.Time, cfg RiskConfig) RiskReport {
+	nan := Number(math.NaN())
+	report := RiskReport{
+		AnnualizedReturn:     nan,
+		AnnualizedVolatility: nan,
+		Sharpe:               nan,
+		Sortino:              nan,
+		Calmar:               nan,
+		Drawdown:             MaxDrawdown(prices, times),
+	}
+
+	returns := Returns(prices)
+	if len(returns) < 2 {
+		return report
+	}
+
+	ppy := float64(cfg.PeriodsPerYear)
+	rf := math.Pow(1+cfg.RiskFreeRate, 1/ppy) - 1
+	mean := utils.Average(returns)
+	sd := stdDev(returns, mean)
+
+	growth := prices[len(prices)-1] / prices[0]
+	annReturn := math.NaN()
+	if growth > 0 {
+		annReturn = math.Pow(growth, ppy/float64(len(returns))) - 1
+	}
+	report.AnnualizedReturn = Number(annReturn)
+	report.AnnualizedVolatility = Number(sd * math.Sqrt(ppy))
+
+	if sd > 0 {
+		report.Sharpe = Number((mean - rf) / sd * math.Sqrt(ppy))
+	}
+
+	var downSq float64
+	for _, r := range returns {
+		if d := r - rf; d < 0 {
+			downSq += d * d
+		}
+	}
+	if downside := math.Sqrt(downSq / float64(len(returns))); downside > 0 {
+		report.Sortino = Number((mean - rf) / downside * math.Sqrt(ppy))
+	}
+
+	if mdd := float64(report.Drawdown.MaxDrawdown); mdd > 0 {
+		report.Calmar = Number(annReturn / mdd)
+	}
+
+	var simulated []float64
+	if cfg.Simulations > 0 {
+		simulated = simulateReturns(returns, cfg.Simulations, cfg.Seed)
+	}
+	sorted := append([]float64(nil), returns...)
+	sort.Float64s(sorted)
+
+	for _, conf := range cfg.Confidence {
+		v := VaRReport{
+			Confidence: conf,
+			Historical: historicalVaR(sorted, conf),
+			Parametric: parametricVaR(mean, sd, conf),
+			MonteCarlo: TailRisk{VaR: nan, CVaR: nan},
+		}
+		if simulated != nil {
+			v.MonteCarlo = historicalVaR(simulated, conf)
+		}
+		report.VaR = append(report.VaR, v)
+	}
+	return report
+}
+
+// historicalVaR reads the loss quantile off sorted returns
+func historicalVaR(sorted []float64, conf float64) TailRisk {
+	if len(sorted) == 0 {
+		return TailRisk{VaR: Number(math.NaN()), CVaR: Number(math.NaN())}
+	}
+	cut := int(math.Floor((1 - conf) * float64(len(sorted))))
+	cut = min(max(cut, 0), len(sorted)-1)
+	return TailRisk{
+		VaR:  Number(-sorted[cut]),
+		CVaR: Number(-utils.Average(sorted[:cut+1])),
+	}
+}
+
+// parametricVaR assumes normally distributed returns
+func parametricVaR(mean, sd, conf float64) TailRisk {
+	z := normalQuantile(1 - conf)
+	density := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+	return TailRisk{
+		VaR:  Number(-(mean + z*sd)),
+		CVaR: Number(-(mean - sd*density/(1-conf))),
+	}
+}
+
+// simulateReturns draws n simple returns from a lognormal model fitted to
+// the observed returns, sorted ascending
+func simulateReturns(returns []float64, n int, seed uint64) []float64 {
+	logs := make([]float64, 0, len(returns))
+	for _, r := range returns {
+		if r > -1 {
+			logs = append(logs, math.Log1p(r))
+		}
+	}
+	if len(logs) < 2 {
+		return nil
+	}
+	mu := utils.Average(logs)
+	sigma := stdDev(logs, mu)
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.Expm1(mu + sigma*rng.NormFloat64())
+	}
+	sort.Float64s(out)
+	return out
+}
+
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// stdDev is the sample standard deviation around mean
+func stdDev(data []float64, mean float64) float64 {
+	if len(data) < 2 {
+		return 0
+	}
+	var sumSq float64
+	for _, v := range data {
+		sumSq += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sumSq / float64(len(data)-1))
+}
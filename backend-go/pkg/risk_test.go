@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMaxDrawdown(t *testing.T) {
+	prices := []float64{100, 120, 90, 110, 60, 130}
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	times := make([]time.Time, len(prices))
+	for i := range times {
+		times[i] = start.AddDate(0, 0, i)
+	}
+
+	got := MaxDrawdown(prices, times)
+	if !almostEqual(float64(got.MaxDrawdown), 0.5, 1e-9) {
+		t.Errorf("MaxDrawdown = %v, want 0.5", got.MaxDrawdown)
+	}
+	if got.PeakIndex != 1 || got.TroughIndex != 4 {
+		t.Errorf("peak/trough = %d/%d, want 1/4", got.PeakIndex, got.TroughIndex)
+	}
+	if got.PeakDate == nil || !got.PeakDate.Equal(times[1]) || !got.TroughDate.Equal(times[4]) {
+		t.Errorf("dates = %v/%v, want %v/%v", got.PeakDate, got.TroughDate, times[1], times[4])
+	}
+}
+
+func TestRisk(t *testing.T) {
+	// Alternating +2% / -1% returns
+	prices := []float64{100}
+	for i := 0; i < 200; i++ {
+		r := 0.02
+		if i%2 == 1 {
+			r = -0.01
+		}
+		prices = append(prices, prices[len(prices)-1]*(1+r))
+	}
+
+	cfg := DefaultRiskConfig()
+	cfg.RiskFreeRate = 0.02
+	got := Risk(prices, nil, cfg)
+
+	if float64(got.AnnualizedReturn) <= 0 || float64(got.Sharpe) <= 0 || float64(got.Sortino) <= 0 {
+		t.Errorf("report = %+v, want positive return, Sharpe and Sortino", got)
+	}
+	// Downside deviation only counts losing periods, so Sortino > Sharpe here
+	if got.Sortino <= got.Sharpe {
+		t.Errorf("Sortino %v should exceed Sharpe %v", got.Sortino, got.Sharpe)
+	}
+	if !almostEqual(float64(got.Drawdown.MaxDrawdown), 0.01, 1e-9) {
+		t.Errorf("MaxDrawdown = %v, want 0.01", got.Drawdown.MaxDrawdown)
+	}
+	if len(got.VaR) != 2 {
+		t.Fatalf("len(VaR) = %d, want 2", len(got.VaR))
+	}
+
+	v95 := got.VaR[0]
+	if !almostEqual(float64(v95.Historical.VaR), 0.01, 1e-9) || !almostEqual(float64(v95.Historical.CVaR), 0.01, 1e-9) {
+		t.Errorf("historical = %+v, want 1%% loss", v95.Historical)
+	}
+	for name, tail := range map[string]TailRisk{"parametric": v95.Parametric, "monte_carlo": v95.MonteCarlo} {
+		if math.IsNaN(float64(tail.VaR)) || tail.CVaR < tail.VaR {
+			t.Errorf("%s = %+v, want CVaR >= VaR", name, tail)
+		}
+	}
+	if got.VaR[1].Parametric.VaR <= v95.Parametric.VaR {
+		t.Errorf("99%% VaR %v should exceed 95%% VaR %v", got.VaR[1].Parametric.VaR, v95.Parametric.VaR)
+	}
+
+	// Monte Carlo draws are repeatable for a fixed seed
+	again := Risk(prices, nil, cfg)
+	if again.VaR[0].MonteCarlo != v95.MonteCarlo {
+		t.Errorf("Monte Carlo VaR not repeatable: %+v vs %+v", again.VaR[0].MonteCarlo, v95.MonteCarlo)
+	}
+}
+
+func TestRiskConfig_Validate(t *testing.T) {
+	cfg := DefaultRiskConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() default error = %v", err)
+	}
+	cfg.Confidence = []float64{1.5}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() should reject confidence 1.5")
+	}
+}
@@ -0,0 +1,190 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bar is a single OHLCV row of a price history
+type Bar struct {
+	Time     time.Time `json:"time"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	AdjClose float64   `json:"adj_close"`
+	Volume   float64   `json:"volume"`
+}
+
+// Series is an ordered price history parsed from an upload
+type Series struct {
+	Bars []Bar `json:"bars"`
+	// Skipped counts rows dropped because their close or date did not parse
+	Skipped int `json:"-"`
+}
+
+// ColumnMapping names the CSV header of each Bar field. Headers are matched
+// case-insensitively, an empty name means the column is not read.
+type ColumnMapping struct {
+	Date     string
+	Open     string
+	High     string
+	Low      string
+	Close    string
+	AdjClose string
+	Volume   string
+}
+
+// DefaultColumns matches the CSV layout produced by Yahoo Finance
+func DefaultColumns() ColumnMapping {
+	return ColumnMapping{
+		Date:     "Date",
+		Open:     "Open",
+		High:     "High",
+		Low:      "Low",
+		Close:    "Close",
+		AdjClose: "Adj Close",
+		Volume:   "Volume",
+	}
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05-07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", s)
+}
+
+// ParseCSV reads an OHLCV CSV. Only the close column is required, missing
+// open/high/low/adj close columns fall back to the close and a missing
+// volume column reads as zero. Rows whose close or date cannot be parsed
+// are skipped and counted in Series.Skipped.
+func ParseCSV(r io.Reader, columns ColumnMapping) (*Series, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	index := func(name string) int {
+		if name == "" {
+			return -1
+		}
+		for i, col := range headers {
+			if strings.EqualFold(strings.TrimSpace(col), name) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	closeIdx := index(columns.Close)
+	if closeIdx == -1 {
+		return nil, fmt.Errorf("%q column not found in CSV headers", columns.Close)
+	}
+	dateIdx := index(columns.Date)
+	openIdx := index(columns.Open)
+	highIdx := index(columns.High)
+	lowIdx := index(columns.Low)
+	adjIdx := index(columns.AdjClose)
+	volIdx := index(columns.Volume)
+
+	series := &Series{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV row: %w", err)
+		}
+
+		closeVal, err := fieldFloat(record, closeIdx)
+		if err != nil {
+			series.Skipped++
+			continue
+		}
+
+		bar := Bar{Close: closeVal}
+		if dateIdx != -1 {
+			if dateIdx >= len(record) {
+				series.Skipped++
+				continue
+			}
+			bar.Time, err = parseDate(record[dateIdx])
+			if err != nil {
+				series.Skipped++
+				continue
+			}
+		}
+		bar.Open = fieldFloatOr(record, openIdx, closeVal)
+		bar.High = fieldFloatOr(record, highIdx, closeVal)
+		bar.Low = fieldFloatOr(record, lowIdx, closeVal)
+		bar.AdjClose = fieldFloatOr(record, adjIdx, closeVal)
+		bar.Volume = fieldFloatOr(record, volIdx, 0)
+
+		series.Bars = append(series.Bars, bar)
+	}
+
+	return series, nil
+}
+
+func fieldFloat(record []string, idx int) (float64, error) {
+	if idx < 0 || idx >= len(record) {
+		return 0, fmt.Errorf("column %d missing", idx)
+	}
+	return strconv.ParseFloat(strings.TrimSpace(record[idx]), 64)
+}
+
+func fieldFloatOr(record []string, idx int, fallback float64) float64 {
+	val, err := fieldFloat(record, idx)
+	if err != nil {
+		return fallback
+	}
+	return val
+}
+
+// Len returns the number of bars
+func (s *Series) Len() int {
+	return len(s.Bars)
+}
+
+// Times returns the timestamp of every bar
+func (s *Series) Times() []time.Time {
+	out := make([]time.Time, len(s.Bars))
+	for i, b := range s.Bars {
+		out[i] = b.Time
+	}
+	return out
+}
+
+func (s *Series) column(get func(Bar) float64) []float64 {
+	out := make([]float64, len(s.Bars))
+	for i, b := range s.Bars {
+		out[i] = get(b)
+	}
+	return out
+}
+
+func (s *Series) Opens() []float64     { return s.column(func(b Bar) float64 { return b.Open }) }
+func (s *Series) Highs() []float64     { return s.column(func(b Bar) float64 { return b.High }) }
+func (s *Series) Lows() []float64      { return s.column(func(b Bar) float64 { return b.Low }) }
+func (s *Series) Closes() []float64    { return s.column(func(b Bar) float64 { return b.Close }) }
+func (s *Series) AdjCloses() []float64 { return s.column(func(b Bar) float64 { return b.AdjClose }) }
+func (s *Series) Volumes() []float64   { return s.column(func(b Bar) float64 { return b.Volume }) }
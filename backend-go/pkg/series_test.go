@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSV(t *testing.T) {
+	csvData := `date,OPEN,High,low,close,Adj Close,Volume,Ticker
+2023-01-03 00:00:00-05:00,10,12,9,11,10.5,1000,AAPL
+2023-01-04,11,13,10,12,11.5,2000,AAPL
+2023-01-05,11,13,10,oops,11.5,2000,AAPL`
+
+	series, err := ParseCSV(strings.NewReader(csvData), DefaultColumns())
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if series.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", series.Len())
+	}
+	if series.Skipped != 1 {
+		t.Errorf("Skipped = %d, want 1", series.Skipped)
+	}
+
+	want := Bar{
+		Time:     time.Date(2023, 1, 3, 0, 0, 0, 0, time.FixedZone("", -5*3600)),
+		Open:     10,
+		High:     12,
+		Low:      9,
+		Close:    11,
+		AdjClose: 10.5,
+		Volume:   1000,
+	}
+	got := series.Bars[0]
+	if !got.Time.Equal(want.Time) || got.Open != want.Open || got.High != want.High ||
+		got.Low != want.Low || got.Close != want.Close || got.AdjClose != want.AdjClose || got.Volume != want.Volume {
+		t.Errorf("Bars[0] = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseCSV_CustomColumnsAndFallbacks(t *testing.T) {
+	csvData := `Day,Last
+2023-01-03,11
+2023-01-04,12`
+
+	columns := DefaultColumns()
+	columns.Date = "Day"
+	columns.Close = "Last"
+
+	series, err := ParseCSV(strings.NewReader(csvData), columns)
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if series.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", series.Len())
+	}
+	bar := series.Bars[1]
+	if bar.High != 12 || bar.Low != 12 || bar.Open != 12 || bar.Volume != 0 {
+		t.Errorf("Bars[1] = %+v, want OHL to fall back to close and zero volume", bar)
+	}
+}
+
+func TestParseCSV_MissingClose(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("Date,Open\n2023-01-03,1"), DefaultColumns())
+	if err == nil || !strings.Contains(err.Error(), "Close") {
+		t.Errorf("ParseCSV() error = %v, want missing Close column", err)
+	}
+}
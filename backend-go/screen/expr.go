@@ -0,0 +1,308 @@
+// Package screen filters and ranks tickers by expressions over their price
+// history and indicators, e.g. "rsi:14 < 30" or
+// "macd.histogram crosses_above 0".
+package screen
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+// Operand is one side of a filter. It evaluates to a series aligned with
+// the bars, the screen reads its last one or two values.
+type Operand interface {
+	// String is the operand as written without spaces, the column name of
+	// its value in the result table
+	String() string
+	eval(e *env) (pkg.Line, error)
+}
+
+// env holds the price columns of one ticker and the indicators already
+// computed for it, so an indicator used by several filters runs once
+type env struct {
+	series *pkg.Series
+	in     pkg.Inputs
+	memo   map[string]any
+}
+
+func newEnv(series *pkg.Series) *env {
+	return &env{
+		series: series,
+		in:     pkg.InputsFrom(series, pkg.InputClose, pkg.InputHigh, pkg.InputLow, pkg.InputVolume),
+		memo:   make(map[string]any),
+	}
+}
+
+// constant is a number literal
+type constant struct {
+	raw   string
+	value float64
+}
+
+func (c constant) String() string { return c.raw }
+
+func (c constant) eval(e *env) (pkg.Line, error) {
+	out := make(pkg.Line, e.series.Len())
+	for i := range out {
+		out[i] = c.value
+	}
+	return out, nil
+}
+
+// price is a column of the bars
+type price string
+
+var prices = map[string]func(*pkg.Series) []float64{
+	"open":   (*pkg.Series).Opens,
+	"high":   (*pkg.Series).Highs,
+	"low":    (*pkg.Series).Lows,
+	"close":  (*pkg.Series).Closes,
+	"volume": (*pkg.Series).Volumes,
+}
+
+func (p price) String() string { return string(p) }
+
+func (p price) eval(e *env) (pkg.Line, error) {
+	return prices[string(p)](e.series), nil
+}
+
+// indicator is an indicator spec, optionally picking one line of a multi
+// line result, written name.component:params such as bb.upper:20/2
+type indicator struct {
+	raw       string
+	spec      pkg.Spec
+	component string
+}
+
+func (ind indicator) String() string { return ind.raw }
+
+func (ind indicator) eval(e *env) (pkg.Line, error) {
+	key := fmt.Sprint(ind.spec.Indicator.Name(), ind.spec.Params)
+	v, ok := e.memo[key]
+	if !ok {
+		v = ind.spec.Compute(e.in)
+		e.memo[key] = v
+	}
+	return component(v, ind.component, e.series.Len())
+}
+
+// component picks the named line of an indicator result. A scalar result
+// only has a value at the last bar.
+func component(v any, name string, n int) (pkg.Line, error) {
+	switch v := v.(type) {
+	case pkg.Line:
+		if name == "" {
+			return v, nil
+		}
+	case pkg.Bands:
+		switch name {
+		case "upper":
+			return v.Upper, nil
+		case "", "middle":
+			return v.Middle, nil
+		case "lower":
+			return v.Lower, nil
+		}
+		return nil, fmt.Errorf("unknown component %q, expected upper, middle or lower", name)
+	case pkg.MACDLines:
+		switch name {
+		case "", "macd":
+			return v.MACD, nil
+		case "signal":
+			return v.Signal, nil
+		case "histogram":
+			return v.Histogram, nil
+		}
+		return nil, fmt.Errorf("unknown component %q, expected macd, signal or histogram", name)
+	case pkg.Number:
+		if name == "" {
+			out := pkg.AlignRight([]float64{float64(v)}, n)
+			return out, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported indicator result %T", v)
+	}
+	return nil, fmt.Errorf("has no component %q", name)
+}
+
+// change is the percent change of an operand over the last bars
+type change struct {
+	raw  string
+	of   Operand
+	bars int
+}
+
+func (c change) String() string { return c.raw }
+
+func (c change) eval(e *env) (pkg.Line, error) {
+	values, err := c.of.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	out := make(pkg.Line, len(values))
+	for i := range out {
+		out[i] = math.NaN()
+		if i >= c.bars && values[i-c.bars] != 0 {
+			out[i] = (values[i]/values[i-c.bars] - 1) * 100
+		}
+	}
+	return out, nil
+}
+
+// ParseOperand parses a number, a price column (open, high, low, close,
+// volume), an indicator spec such as rsi:14 or macd.histogram:12/26/9, or
+// change(operand, bars), the percent change over that many bars
+func ParseOperand(s string) (Operand, error) {
+	raw := strings.Join(strings.Fields(s), "")
+	if raw == "" {
+		return nil, fmt.Errorf("missing operand")
+	}
+	lower := strings.ToLower(raw)
+
+	if v, err := strconv.ParseFloat(raw, 64); err == nil {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("%q is not a finite number", raw)
+		}
+		return constant{raw: raw, value: v}, nil
+	}
+	if _, ok := prices[lower]; ok {
+		return price(lower), nil
+	}
+	if inner, ok := strings.CutPrefix(lower, "change("); ok {
+		inner, ok = strings.CutSuffix(inner, ")")
+		i := strings.LastIndex(inner, ",")
+		if !ok || i == -1 {
+			return nil, fmt.Errorf("%q: expected change(operand, bars)", raw)
+		}
+		bars, err := strconv.Atoi(inner[i+1:])
+		if err != nil || bars < 1 || bars > pkg.MaxPeriod {
+			return nil, fmt.Errorf("%q: bars must be a positive integer up to %d", raw, pkg.MaxPeriod)
+		}
+		of, err := ParseOperand(inner[:i])
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", raw, err)
+		}
+		return change{raw: lower, of: of, bars: bars}, nil
+	}
+
+	head, params, hasParams := strings.Cut(lower, ":")
+	name, comp, _ := strings.Cut(head, ".")
+	entry := name
+	if hasParams {
+		entry += ":" + params
+	}
+	spec, err := pkg.Indicators.ParseSpec(entry)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", raw, err)
+	}
+	if outputs := spec.Indicator.Outputs(); comp != "" && !slices.Contains(outputs, comp) {
+		if outputs == nil {
+			return nil, fmt.Errorf("%q: %s has no component %q", raw, name, comp)
+		}
+		return nil, fmt.Errorf("%q: unknown %s component %q, expected one of %s", raw, name, comp, strings.Join(outputs, ", "))
+	}
+	return indicator{raw: lower, spec: spec, component: comp}, nil
+}
+
+// Op compares the two operands of a filter
+type Op string
+
+const (
+	Less         Op = "<"
+	LessEqual    Op = "<="
+	Greater      Op = ">"
+	GreaterEqual Op = ">="
+	// CrossesAbove holds when the left operand was at or below the right
+	// one on the previous bar and is above it on the last bar
+	CrossesAbove Op = "crosses_above"
+	CrossesBelow Op = "crosses_below"
+)
+
+// Filter is a condition a ticker must meet on its last bar
+type Filter struct {
+	Left  Operand
+	Op    Op
+	Right Operand
+}
+
+func (f Filter) String() string {
+	return f.Left.String() + " " + string(f.Op) + " " + f.Right.String()
+}
+
+// ParseFilter parses "left op right", op is one of < <= > >= crosses_above
+// crosses_below
+func ParseFilter(s string) (Filter, error) {
+	var (
+		f           Filter
+		left, right string
+		found       bool
+	)
+	for _, op := range []Op{CrossesAbove, CrossesBelow} {
+		fields := strings.Fields(s)
+		for i, field := range fields {
+			if strings.EqualFold(field, string(op)) {
+				left, right = strings.Join(fields[:i], " "), strings.Join(fields[i+1:], " ")
+				f.Op, found = op, true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		i := strings.IndexAny(s, "<>")
+		if i == -1 {
+			return f, fmt.Errorf("%q: expected a comparison (< <= > >=) or crosses_above/crosses_below", s)
+		}
+		f.Op, found = Op(s[i:i+1]), true
+		right = s[i+1:]
+		if strings.HasPrefix(right, "=") {
+			f.Op += "="
+			right = right[1:]
+		}
+		left = s[:i]
+	}
+
+	var err error
+	if f.Left, err = ParseOperand(left); err != nil {
+		return f, fmt.Errorf("%q: %w", s, err)
+	}
+	if f.Right, err = ParseOperand(right); err != nil {
+		return f, fmt.Errorf("%q: %w", s, err)
+	}
+	return f, nil
+}
+
+// match tests the condition on the last bar, an undefined value never
+// matches
+func (f Filter) match(left, right pkg.Line) bool {
+	n := len(left)
+	if n == 0 {
+		return false
+	}
+	a, b := left[n-1], right[n-1]
+	switch f.Op {
+	case Less:
+		return a < b
+	case LessEqual:
+		return a <= b
+	case Greater:
+		return a > b
+	case GreaterEqual:
+		return a >= b
+	}
+	if n < 2 {
+		return false
+	}
+	prevA, prevB := left[n-2], right[n-2]
+	if f.Op == CrossesAbove {
+		return prevA <= prevB && a > b
+	}
+	return prevA >= prevB && a < b
+}
@@ -0,0 +1,193 @@
+package screen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+// Query is a parsed screen: every filter must hold for a ticker to match,
+// matches are ranked by the sort operand
+type Query struct {
+	Filters []Filter
+	// Sort is nil to rank by ticker
+	Sort Operand
+	Desc bool
+	// Limit caps the returned rows, 0 for no limit
+	Limit int
+	// All keeps the tickers that do not match, ranked after the matches
+	All bool
+}
+
+// Parse builds a query from filter expressions, a sort operand (empty to
+// rank by ticker) and an order, "asc" or "desc" (the default)
+func Parse(filters []string, sortBy, order string) (Query, error) {
+	var (
+		q    Query
+		errs []error
+	)
+	for _, raw := range filters {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		f, err := ParseFilter(raw)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		q.Filters = append(q.Filters, f)
+	}
+	if strings.TrimSpace(sortBy) != "" {
+		op, err := ParseOperand(sortBy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sort: %w", err))
+		}
+		q.Sort = op
+	}
+	switch strings.ToLower(strings.TrimSpace(order)) {
+	case "", "desc":
+		q.Desc = true
+	case "asc":
+	default:
+		errs = append(errs, fmt.Errorf("order must be asc or desc, got %q", order))
+	}
+	return q, errors.Join(errs...)
+}
+
+// Columns names the values reported for every ticker: the close, each
+// operand of the filters that is not a number, then the sort operand
+func (q Query) Columns() []string {
+	var operands []Operand
+	operands = append(operands, price("close"))
+	for _, f := range q.Filters {
+		operands = append(operands, f.Left, f.Right)
+	}
+	if q.Sort != nil {
+		operands = append(operands, q.Sort)
+	}
+	var columns []string
+	seen := map[string]bool{}
+	for _, op := range operands {
+		if _, ok := op.(constant); ok || seen[op.String()] {
+			continue
+		}
+		seen[op.String()] = true
+		columns = append(columns, op.String())
+	}
+	return columns
+}
+
+// Row is one ticker of the result table
+type Row struct {
+	Ticker  string `json:"ticker"`
+	Rank    int    `json:"rank"`
+	Matched bool   `json:"matched"`
+	// Date is the last bar the values are read at
+	Date   string                `json:"date,omitempty"`
+	Values map[string]pkg.Number `json:"values"`
+	// Failed lists the filters that did not hold
+	Failed []string `json:"failed,omitempty"`
+}
+
+// Evaluate runs the query's filters over one ticker's history
+func (q Query) Evaluate(ticker string, series *pkg.Series) (Row, error) {
+	row := Row{Ticker: ticker, Matched: true, Values: make(map[string]pkg.Number)}
+	n := series.Len()
+	if n == 0 {
+		return row, fmt.Errorf("no bars to screen")
+	}
+	if t := series.Bars[n-1].Time; !t.IsZero() {
+		row.Date = t.Format("2006-01-02")
+	}
+
+	e := newEnv(series)
+	lines := make(map[string]pkg.Line)
+	eval := func(op Operand) (pkg.Line, error) {
+		if line, ok := lines[op.String()]; ok {
+			return line, nil
+		}
+		line, err := op.eval(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		lines[op.String()] = line
+		return line, nil
+	}
+
+	for _, f := range q.Filters {
+		left, err := eval(f.Left)
+		if err != nil {
+			return row, err
+		}
+		right, err := eval(f.Right)
+		if err != nil {
+			return row, err
+		}
+		if !f.match(left, right) {
+			row.Matched = false
+			row.Failed = append(row.Failed, f.String())
+		}
+	}
+	if q.Sort != nil {
+		if _, err := eval(q.Sort); err != nil {
+			return row, err
+		}
+	}
+	if _, err := eval(price("close")); err != nil {
+		return row, err
+	}
+	for _, column := range q.Columns() {
+		row.Values[column] = pkg.Number(lines[column][n-1])
+	}
+	return row, nil
+}
+
+// Rank orders the rows, drops those that did not match unless All is set,
+// numbers them from 1 and applies the limit. Matches come first, then rows
+// by the sort value with undefined values last, ties by ticker.
+func (q Query) Rank(rows []Row) []Row {
+	out := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		if row.Matched || q.All {
+			out = append(out, row)
+		}
+	}
+
+	value := func(r Row) float64 {
+		if q.Sort == nil {
+			return math.NaN()
+		}
+		return float64(r.Values[q.Sort.String()])
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Matched != b.Matched {
+			return a.Matched
+		}
+		va, vb := value(a), value(b)
+		switch {
+		case math.IsNaN(va) && math.IsNaN(vb), va == vb:
+			return a.Ticker < b.Ticker
+		case math.IsNaN(va):
+			return false
+		case math.IsNaN(vb):
+			return true
+		case q.Desc:
+			return va > vb
+		default:
+			return va < vb
+		}
+	})
+
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	for i := range out {
+		out[i].Rank = i + 1
+	}
+	return out
+}
@@ -0,0 +1,215 @@
+package screen
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+func makeSeries(closes ...float64) *pkg.Series {
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	series := &pkg.Series{}
+	for i, c := range closes {
+		series.Bars = append(series.Bars, pkg.Bar{
+			Time: start.AddDate(0, 0, i), Open: c, High: c, Low: c, Close: c, AdjClose: c,
+		})
+	}
+	return series
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"rsi:14 < 30", "rsi:14 < 30"},
+		{"close>=ma:50", "close >= ma:50"},
+		{"MACD.Histogram CROSSES_ABOVE 0", "macd.histogram crosses_above 0"},
+		{"change(close, 5) > 2.5", "change(close,5) > 2.5"},
+		{"close crosses_below bb.lower:20/2", "close crosses_below bb.lower:20/2"},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.in)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) error = %v", tt.in, err)
+			continue
+		}
+		if f.String() != tt.want {
+			t.Errorf("ParseFilter(%q) = %q, want %q", tt.in, f.String(), tt.want)
+		}
+	}
+}
+
+func TestParseFilter_Errors(t *testing.T) {
+	for _, in := range []string{
+		"rsi:14",
+		"rsi:14 < ",
+		"nope > 1",
+		"rsi.upper < 30",
+		"bb.histogram:20/2 > 0",
+		"change(close) > 1",
+		"change(close, 0) > 1",
+		// A window this long would allocate gigabytes per ticker
+		"ma:2000000000 > 0",
+		"change(ema:99999999, 5) > 0",
+	} {
+		if _, err := ParseFilter(in); err == nil {
+			t.Errorf("ParseFilter(%q) error = nil, want an error", in)
+		}
+	}
+
+	_, err := Parse([]string{"rsi < 30", "bad", "close > 1"}, "nope", "sideways")
+	if err == nil || strings.Count(err.Error(), "\n") != 2 {
+		t.Errorf("Parse() error = %v, want every problem reported", err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	q, err := Parse([]string{"ma:2 crosses_above ma:4", "change(close, 2) > 10"}, "change(close,2)", "")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	row, err := q.Evaluate("UP", makeSeries(10, 9, 8, 7, 9, 12))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !row.Matched || len(row.Failed) != 0 {
+		t.Errorf("Matched = %v, failed %v, want a match", row.Matched, row.Failed)
+	}
+	if row.Date != "2023-01-07" {
+		t.Errorf("Date = %q, want the last bar", row.Date)
+	}
+	// ma:2 moved from 8 to 10.5 above ma:4 which went 8.25 to 9
+	if got := float64(row.Values["ma:2"]); got != 10.5 {
+		t.Errorf("ma:2 = %v, want 10.5", got)
+	}
+	if got := float64(row.Values["change(close,2)"]); math.Abs(got-(12.0/7-1)*100) > 1e-9 {
+		t.Errorf("change(close,2) = %v", got)
+	}
+	wantColumns := []string{"close", "ma:2", "ma:4", "change(close,2)"}
+	if got := q.Columns(); strings.Join(got, ",") != strings.Join(wantColumns, ",") {
+		t.Errorf("Columns() = %v, want %v", got, wantColumns)
+	}
+
+	// Already above on the previous bar, so no crossing
+	row, err = q.Evaluate("FLAT", makeSeries(10, 9, 8, 11, 12, 13))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if row.Matched || len(row.Failed) != 1 || row.Failed[0] != "ma:2 crosses_above ma:4" {
+		t.Errorf("Matched = %v, failed %v, want the crossing to fail", row.Matched, row.Failed)
+	}
+
+	// Too short for ma:4, undefined values never match
+	row, err = q.Evaluate("SHORT", makeSeries(1, 2))
+	if err != nil || row.Matched {
+		t.Errorf("Evaluate() = %+v, %v, want no match", row, err)
+	}
+
+	if _, err := q.Evaluate("EMPTY", &pkg.Series{}); err == nil {
+		t.Error("Evaluate() of an empty series error = nil")
+	}
+}
+
+func TestEvaluate_RSI(t *testing.T) {
+	// Wilder's 14 period example as published by StockCharts, the last two
+	// RSI values are 33.08 and 37.77
+	wilder := makeSeries(
+		44.3389, 44.0902, 44.1497, 43.6124, 44.2779, 44.8264, 45.0955, 45.4245,
+		45.8433, 46.0826, 45.8931, 46.0328, 45.6140, 46.2820, 46.2820, 46.0028,
+		46.0328, 46.4116, 46.2222, 45.6439, 46.2122, 46.2521, 45.7137, 46.4515,
+		45.7835, 45.3548, 44.0288, 44.1783, 44.2181, 44.5672, 43.4205, 42.6628,
+		43.1314,
+	)
+	falling, rising := make([]float64, 20), make([]float64, 20)
+	for i := range falling {
+		falling[i], rising[i] = 100-float64(i), 100+float64(i)
+	}
+
+	q, err := Parse([]string{"rsi:14 < 40", "rsi:14 crosses_above 35"}, "rsi:14", "asc")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	row, err := q.Evaluate("WILDER", wilder)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !row.Matched {
+		t.Errorf("WILDER failed %v, want a match", row.Failed)
+	}
+	if got := float64(row.Values["rsi:14"]); math.Abs(got-37.77) > 0.01 {
+		t.Errorf("rsi:14 = %v, want 37.77", got)
+	}
+
+	oversold, err := Parse([]string{"rsi:14 < 30"}, "rsi:14", "asc")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	var rows []Row
+	for ticker, series := range map[string]*pkg.Series{
+		"WILDER":  wilder,
+		"FALLING": makeSeries(falling...),
+		"RISING":  makeSeries(rising...),
+	} {
+		row, err := oversold.Evaluate(ticker, series)
+		if err != nil {
+			t.Fatalf("Evaluate(%s) error = %v", ticker, err)
+		}
+		rows = append(rows, row)
+	}
+	oversold.All = true
+	ranked := oversold.Rank(rows)
+	want := []struct {
+		ticker  string
+		matched bool
+		rsi     float64
+	}{{"FALLING", true, 0}, {"WILDER", false, 37.77}, {"RISING", false, 100}}
+	for i, w := range want {
+		got := ranked[i]
+		if got.Ticker != w.ticker || got.Matched != w.matched || math.Abs(float64(got.Values["rsi:14"])-w.rsi) > 0.01 {
+			t.Errorf("row %d = %s matched %v rsi %v, want %s matched %v rsi %v",
+				i, got.Ticker, got.Matched, got.Values["rsi:14"], w.ticker, w.matched, w.rsi)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	q, err := Parse(nil, "rsi:14", "asc")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	row := func(ticker string, matched bool, rsi float64) Row {
+		return Row{Ticker: ticker, Matched: matched, Values: map[string]pkg.Number{"rsi:14": pkg.Number(rsi)}}
+	}
+	rows := []Row{
+		row("C", true, 40), row("A", true, math.NaN()), row("B", true, 20),
+		row("D", false, 10), row("E", true, 20),
+	}
+
+	tickers := func(rows []Row) string {
+		var out []string
+		for i, r := range rows {
+			if r.Rank != i+1 {
+				t.Errorf("%s Rank = %d, want %d", r.Ticker, r.Rank, i+1)
+			}
+			out = append(out, r.Ticker)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := tickers(q.Rank(rows)); got != "B,E,C,A" {
+		t.Errorf("Rank() = %s, want B,E,C,A", got)
+	}
+
+	q.Desc, q.All, q.Limit = true, true, 4
+	if got := tickers(q.Rank(rows)); got != "C,B,E,A" {
+		t.Errorf("Rank() desc = %s, want C,B,E,A", got)
+	}
+	q.Limit = 0
+	if got := tickers(q.Rank(rows)); got != "C,B,E,A,D" {
+		t.Errorf("Rank() all = %s, want the unmatched D last", got)
+	}
+}
@@ -0,0 +1,237 @@
+// Package store persists daily price history per ticker in a local directory,
+// one CSV file per ticker, so uploads only need to carry new bars.
+package store
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+const dateLayout = "2006-01-02"
+
+var (
+	// ErrNotFound is returned for a ticker with no stored history
+	ErrNotFound = errors.New("no stored series for ticker")
+	// ErrInvalidTicker is returned for names that cannot be a file name
+	ErrInvalidTicker = errors.New("invalid ticker")
+)
+
+// Store is safe for concurrent use. Writes replace a ticker's file
+// atomically, so a crash never leaves a half-written history behind.
+type Store struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*sync.RWMutex
+}
+
+// New returns a store rooted at dir, which is created on the first write
+func New(dir string) *Store {
+	return &Store{dir: dir, locks: make(map[string]*sync.RWMutex)}
+}
+
+// UpsertResult counts what an upsert changed
+type UpsertResult struct {
+	Added    int `json:"added"`
+	Replaced int `json:"replaced"`
+	// Unchanged counts bars identical to the stored ones
+	Unchanged int `json:"unchanged"`
+	// Skipped counts bars without a date, which cannot be keyed
+	Skipped int `json:"skipped"`
+	Total   int `json:"total"`
+}
+
+// NormalizeTicker upper-cases a ticker and checks it is safe as a file name.
+// Letters, digits and . - _ ^ = are allowed, e.g. BRK-B, ^GSPC or EURUSD=X.
+func NormalizeTicker(ticker string) (string, error) {
+	t := strings.ToUpper(strings.TrimSpace(ticker))
+	if t == "" || len(t) > 32 || strings.Trim(t, ".") == "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTicker, ticker)
+	}
+	for _, r := range t {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(".-_^=", r)) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidTicker, ticker)
+		}
+	}
+	return t, nil
+}
+
+func (s *Store) lock(ticker string) *sync.RWMutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.locks[ticker]
+	if !ok {
+		l = &sync.RWMutex{}
+		s.locks[ticker] = l
+	}
+	return l
+}
+
+func (s *Store) path(ticker string) string {
+	return filepath.Join(s.dir, ticker+".csv")
+}
+
+// day keys a bar by its calendar date, the store holds one bar per day
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Upsert merges bars into the ticker's history. A bar whose date is already
+// stored replaces it, so overlapping uploads do not duplicate days. The file
+// is only rewritten when a bar was added or changed.
+func (s *Store) Upsert(ticker string, bars []pkg.Bar) (UpsertResult, error) {
+	var res UpsertResult
+	ticker, err := NormalizeTicker(ticker)
+	if err != nil {
+		return res, err
+	}
+	l := s.lock(ticker)
+	l.Lock()
+	defer l.Unlock()
+
+	existing, err := s.read(ticker)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return res, err
+	}
+	byDay := make(map[time.Time]pkg.Bar, len(existing)+len(bars))
+	for _, bar := range existing {
+		byDay[bar.Time] = bar
+	}
+	for _, bar := range bars {
+		if bar.Time.IsZero() {
+			res.Skipped++
+			continue
+		}
+		bar.Time = day(bar.Time)
+		switch stored, ok := byDay[bar.Time]; {
+		case !ok:
+			res.Added++
+		case sameBar(stored, bar):
+			res.Unchanged++
+			continue
+		default:
+			res.Replaced++
+		}
+		byDay[bar.Time] = bar
+	}
+
+	merged := make([]pkg.Bar, 0, len(byDay))
+	for _, bar := range byDay {
+		merged = append(merged, bar)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Time.Before(merged[j].Time) })
+	res.Total = len(merged)
+	if res.Added == 0 && res.Replaced == 0 {
+		return res, nil
+	}
+	return res, s.write(ticker, merged)
+}
+
+// sameBar reports whether two bars of the same day hold the same values
+func sameBar(a, b pkg.Bar) bool {
+	return a.Time.Equal(b.Time) && a.Open == b.Open && a.High == b.High && a.Low == b.Low &&
+		a.Close == b.Close && a.AdjClose == b.AdjClose && a.Volume == b.Volume
+}
+
+// Range returns the stored bars dated from..to inclusive, a zero bound is
+// open. ErrNotFound is returned when nothing was ever stored for the ticker.
+func (s *Store) Range(ticker string, from, to time.Time) (*pkg.Series, error) {
+	ticker, err := NormalizeTicker(ticker)
+	if err != nil {
+		return nil, err
+	}
+	l := s.lock(ticker)
+	l.RLock()
+	defer l.RUnlock()
+
+	bars, err := s.read(ticker)
+	if err != nil {
+		return nil, err
+	}
+	lo := sort.Search(len(bars), func(i int) bool { return from.IsZero() || !bars[i].Time.Before(day(from)) })
+	hi := sort.Search(len(bars), func(i int) bool { return !to.IsZero() && bars[i].Time.After(day(to)) })
+	if lo > hi {
+		lo = hi
+	}
+	return &pkg.Series{Bars: bars[lo:hi]}, nil
+}
+
+// Tickers lists the stored tickers in alphabetical order
+func (s *Store) Tickers() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tickers []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".csv"); ok && !e.IsDir() {
+			tickers = append(tickers, name)
+		}
+	}
+	sort.Strings(tickers)
+	return tickers, nil
+}
+
+func (s *Store) read(ticker string) ([]pkg.Bar, error) {
+	f, err := os.Open(s.path(ticker))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w %s", ErrNotFound, ticker)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	series, err := pkg.ParseCSV(f, pkg.DefaultColumns())
+	if err != nil {
+		return nil, fmt.Errorf("reading stored series %s: %w", ticker, err)
+	}
+	for i := range series.Bars {
+		series.Bars[i].Time = day(series.Bars[i].Time)
+	}
+	return series.Bars, nil
+}
+
+func (s *Store) write(ticker string, bars []pkg.Bar) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("creating series store: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ticker+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing stored series %s: %w", ticker, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := csv.NewWriter(tmp)
+	w.Write([]string{"Date", "Open", "High", "Low", "Close", "Adj Close", "Volume"})
+	format := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for _, bar := range bars {
+		w.Write([]string{
+			bar.Time.Format(dateLayout),
+			format(bar.Open), format(bar.High), format(bar.Low),
+			format(bar.Close), format(bar.AdjClose), format(bar.Volume),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing stored series %s: %w", ticker, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing stored series %s: %w", ticker, err)
+	}
+	return os.Rename(tmp.Name(), s.path(ticker))
+}
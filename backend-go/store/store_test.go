@@ -0,0 +1,166 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Samudra-G/stockprediction-refactored/pkg"
+)
+
+func bar(date string, close float64) pkg.Bar {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		panic(err)
+	}
+	return pkg.Bar{Time: t, Open: close, High: close + 1, Low: close - 1, Close: close, AdjClose: close, Volume: 1000}
+}
+
+func TestStore_UpsertDeduplicates(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "series"))
+
+	res, err := s.Upsert("aapl", []pkg.Bar{bar("2023-01-02", 1), bar("2023-01-03", 2), bar("2023-01-04", 3), {Close: 9}})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if res != (UpsertResult{Added: 3, Skipped: 1, Total: 3}) {
+		t.Errorf("Upsert() = %+v", res)
+	}
+
+	// An overlapping upload replaces the shared days and extends the range,
+	// a time of day does not make a bar a new day
+	late := bar("2023-01-05", 5)
+	late.Time = late.Time.Add(16 * time.Hour)
+	res, err = s.Upsert("AAPL", []pkg.Bar{bar("2023-01-04", 30), late})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if res != (UpsertResult{Added: 1, Replaced: 1, Total: 4}) {
+		t.Errorf("Upsert() = %+v", res)
+	}
+
+	// A fresh store over the same directory reads the history back
+	got, err := New(s.dir).Range("Aapl", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Range() error = %v", err)
+	}
+	want := []float64{1, 2, 30, 5}
+	if c := got.Closes(); len(c) != len(want) || c[0] != 1 || c[2] != 30 || c[3] != 5 {
+		t.Errorf("closes = %v, want %v", c, want)
+	}
+	if got.Bars[3].Time != time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC) || got.Bars[0].High != 2 {
+		t.Errorf("bars = %+v", got.Bars)
+	}
+
+	tickers, err := s.Tickers()
+	if err != nil || len(tickers) != 1 || tickers[0] != "AAPL" {
+		t.Errorf("Tickers() = %v, %v", tickers, err)
+	}
+}
+
+func TestStore_UpsertUnchanged(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "series"))
+	bars := []pkg.Bar{bar("2023-01-02", 1.1), bar("2023-01-03", 2.2), bar("2023-01-04", 3.3)}
+	if _, err := s.Upsert("AAPL", bars); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	path := s.path("AAPL")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same upload again leaves the file alone
+	res, err := s.Upsert("AAPL", bars)
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if res != (UpsertResult{Unchanged: 3, Total: 3}) {
+		t.Errorf("Upsert() = %+v, want 3 unchanged", res)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.ReadFile(path)
+	if !info.ModTime().Equal(old) || string(after) != string(before) {
+		t.Errorf("file was rewritten, mtime %v want %v", info.ModTime(), old)
+	}
+
+	// One changed field is a replacement
+	changed := bar("2023-01-03", 2.2)
+	changed.Volume = 5
+	res, err = s.Upsert("AAPL", []pkg.Bar{bars[0], changed})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if res != (UpsertResult{Replaced: 1, Unchanged: 1, Total: 3}) {
+		t.Errorf("Upsert() = %+v, want 1 replaced and 1 unchanged", res)
+	}
+}
+
+func TestStore_Range(t *testing.T) {
+	s := New(t.TempDir())
+	if _, err := s.Upsert("MSFT", []pkg.Bar{bar("2023-01-02", 1), bar("2023-01-03", 2), bar("2023-01-05", 3), bar("2023-01-06", 4)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, to string
+		want     int
+	}{
+		{"", "", 4},
+		{"2023-01-03", "", 3},
+		{"", "2023-01-04", 2},
+		{"2023-01-03", "2023-01-05", 2},
+		{"2023-01-07", "", 0},
+	}
+	for _, tt := range tests {
+		var from, to time.Time
+		if tt.from != "" {
+			from = bar(tt.from, 0).Time
+		}
+		if tt.to != "" {
+			to = bar(tt.to, 0).Time
+		}
+		got, err := s.Range("MSFT", from, to)
+		if err != nil {
+			t.Fatalf("Range(%s, %s) error = %v", tt.from, tt.to, err)
+		}
+		if got.Len() != tt.want {
+			t.Errorf("Range(%s, %s) = %d bars, want %d", tt.from, tt.to, got.Len(), tt.want)
+		}
+	}
+
+	if _, err := s.Range("GOOG", time.Time{}, time.Time{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Range() of an unknown ticker error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNormalizeTicker(t *testing.T) {
+	for _, ok := range []string{"aapl", "BRK-B", "^GSPC", "EURUSD=X", "RDS.A"} {
+		if _, err := NormalizeTicker(ok); err != nil {
+			t.Errorf("NormalizeTicker(%q) error = %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "..", "../etc", "a/b", "a b"} {
+		if _, err := NormalizeTicker(bad); !errors.Is(err, ErrInvalidTicker) {
+			t.Errorf("NormalizeTicker(%q) error = %v, want ErrInvalidTicker", bad, err)
+		}
+	}
+
+	// Nothing is written for a rejected ticker
+	dir := t.TempDir()
+	if _, err := New(dir).Upsert("../x", []pkg.Bar{bar("2023-01-02", 1)}); err == nil {
+		t.Error("Upsert() accepted a path as ticker")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("store wrote %d file(s)", len(entries))
+	}
+}
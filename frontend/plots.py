@@ -8,11 +8,18 @@ import time
 
 PREDICTION_CACHE = {}
 
+def x_axis(metrics, length):
+    # The Go backend aligns every series to "dates", fall back to an index
+    dates = metrics.get("dates")
+    if dates and len(dates) >= length:
+        return pd.to_datetime(dates[-length:])
+    return list(range(length))
+
 def plot_moving_averages(metrics):
     if "ma100" in metrics and "ma200" in metrics:
         ma100 = pd.Series(metrics["ma100"])
         ma200 = pd.Series(metrics["ma200"])
-        x_vals = list(range(len(ma100)))
+        x_vals = x_axis(metrics, len(ma100))
 
         fig = go.Figure()
         fig.add_trace(go.Scatter(x=x_vals, y=ma100, mode='lines', line=dict(color='blue'), name='MA100'))
@@ -24,7 +31,7 @@ def plot_moving_averages(metrics):
             height=400,
             margin=dict(l=20, r=20, t=40, b=20),
             title="Moving Averages",
-            xaxis_title="Index",
+            xaxis_title="Date",
             yaxis_title="Value",
             xaxis=dict(showgrid=True, gridcolor='lightgrey'),
             yaxis=dict(showgrid=True, gridcolor='lightgrey'),
@@ -41,7 +48,7 @@ def plot_rsi(metrics):
             min_value=5, max_value=50, value=14, step=1
         )
         rsi = pd.Series(metrics["rsi"][-rsi_period:])
-        x_vals = list(range(len(rsi)))
+        x_vals = x_axis(metrics, len(rsi))
 
         fig = go.Figure()
         fig.add_trace(go.Scatter(x=x_vals, y=rsi, mode='lines', line=dict(color='purple'), name='RSI'))
@@ -50,7 +57,7 @@ def plot_rsi(metrics):
 
         fig.update_layout(
             title=f"RSI (Last {rsi_period} Days)",
-            xaxis_title="Index",
+            xaxis_title="Date",
             yaxis_title="RSI Value",
             xaxis=dict(showgrid=True, gridcolor='lightgrey'),
             yaxis=dict(showgrid=True, gridcolor='lightgrey'),
@@ -79,8 +86,8 @@ def plot_macd(metrics):
 
         macd_line = metrics["macd"][-macd_period:]
         signal_line = metrics["signal"][-macd_period:]
-        histogram = metrics["histogram"][-macd_period:]
-        x_vals = list(range(len(macd_line)))
+        histogram = [val or 0 for val in metrics["histogram"][-macd_period:]]
+        x_vals = x_axis(metrics, len(macd_line))
 
         fig = go.Figure()
         fig.add_trace(go.Scatter(x=x_vals, y=macd_line, mode='lines', line=dict(color='blue'), name='MACD Line'))
@@ -89,7 +96,7 @@ def plot_macd(metrics):
 
         fig.update_layout(
             title=f"MACD (Last {macd_period} Days)",
-            xaxis_title="Index",
+            xaxis_title="Date",
             yaxis_title="Value",
             xaxis=dict(showgrid=True, gridcolor='lightgrey'),
             yaxis=dict(showgrid=True, gridcolor='lightgrey'),
@@ -105,10 +112,10 @@ def plot_prediction_vs_actual(ticker: str):
         status = ""
         result = {}
 
-        while status not in ["success", "failed"]:
+        while status not in ["success", "failed", "cancelled"]:
             result = utils.poll_prediction_status(ticker)
             status = result.get("status", "")
-            if status in ["success", "failed"]:
+            if status in ["success", "failed", "cancelled"]:
                 break
             time.sleep(2)
 
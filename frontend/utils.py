@@ -37,6 +37,9 @@ def send_metrics_to_go(df: pd.DataFrame, ticker: str) -> dict:
     response = requests.post(f"{BACKEND_GO_URL}/metric", files=files, data=data)
     if response.ok:
         return response.json()
+    elif response.status_code == 429:
+        retry_after = response.headers.get("Retry-After", "a few")
+        return {"error": f"Prediction queue is full, try again in {retry_after} seconds."}
     else:
         return {"error": "Failed to get metrics from Go backend."}
 